@@ -11,14 +11,20 @@ type ProcessFileFunc func(path string) error
 // Process walks trhough path directory,
 // and apply fn function on all found files having fileExt extension
 func Process(path, fileExt string, fn ProcessFileFunc) error {
-	err := filepath.Walk(path, processFn(fileExt, fn))
+	return ProcessExts(path, []string{fileExt}, fn)
+}
+
+// ProcessExts walks through path directory,
+// and apply fn function on all found files having one of fileExts extensions
+func ProcessExts(path string, fileExts []string, fn ProcessFileFunc) error {
+	err := filepath.Walk(path, processFn(fileExts, fn))
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func processFn(ext string, pfunc ProcessFileFunc) filepath.WalkFunc {
+func processFn(exts []string, pfunc ProcessFileFunc) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			// silently skip file with error
@@ -30,9 +36,11 @@ func processFn(ext string, pfunc ProcessFileFunc) filepath.WalkFunc {
 		}
 		name := info.Name()
 		fileExt := strings.ToLower(filepath.Ext(name))
-		if fileExt != ext {
-			return nil
+		for _, ext := range exts {
+			if fileExt == ext {
+				return pfunc(path)
+			}
 		}
-		return pfunc(path)
+		return nil
 	}
 }
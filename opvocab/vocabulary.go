@@ -0,0 +1,101 @@
+// Package opvocab maps fiber operation labels, as typed in splice plans, ROP and ZACable files, to canonical operation types
+package opvocab
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Canonical operation types
+const (
+	OpSplice  string = "Epissure"
+	OpPassage string = "Passage"
+	OpWaiting string = "Attente"
+	OpLove    string = "Love"
+)
+
+// Operation label sources (Vocabulary synonym tables)
+const (
+	SourceAll     string = "*" // synonyms shared by all sources
+	SourceBPE     string = "bpe"
+	SourceROP     string = "rop"
+	SourceZACable string = "zacable"
+)
+
+var opTypes = []string{OpSplice, OpPassage, OpWaiting, OpLove}
+
+// Vocabulary maps operation labels, as typed in source documents, to canonical operation types : canonical type by lower-cased label, per source.
+//
+// Labels are looked up in the source table first, then in the SourceAll one
+type Vocabulary map[string]map[string]string
+
+// DefaultVocabulary returns the built-in operation vocabulary
+func DefaultVocabulary() Vocabulary {
+	return Vocabulary{
+		SourceAll: {
+			"epissure": OpSplice,
+			"passage":  OpPassage,
+			"attente":  OpWaiting,
+			"love":     OpLove,
+			"lovage":   OpLove,
+		},
+		SourceZACable: {
+			"epi": OpSplice,
+			"pas": OpPassage,
+			"att": OpWaiting,
+			"lov": OpLove,
+		},
+	}
+}
+
+// LoadVocabulary returns the built-in vocabulary, completed (or overridden) by synonyms defined in given JSON file (ex: {"bpe": {"epi.": "Epissure"}})
+func LoadVocabulary(file string) (Vocabulary, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	synonyms := Vocabulary{}
+	err = json.Unmarshal(content, &synonyms)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse operation vocabulary file '%s': %s", filepath.Base(file), err.Error())
+	}
+	v := DefaultVocabulary()
+	for source, table := range synonyms {
+		for label, opType := range table {
+			if !isOpType(opType) {
+				return nil, fmt.Errorf("operation vocabulary file '%s': unknown operation type '%s' for %s label '%s'", filepath.Base(file), opType, source, label)
+			}
+			if v[source] == nil {
+				v[source] = map[string]string{}
+			}
+			v[source][strings.ToLower(strings.TrimSpace(label))] = opType
+		}
+	}
+	return v, nil
+}
+
+func isOpType(opType string) bool {
+	for _, t := range opTypes {
+		if t == opType {
+			return true
+		}
+	}
+	return false
+}
+
+// Canonical returns the canonical operation type of given label found in given source document. Returned known is false if label is not
+// defined in vocabulary (empty label stands for no operation, and is known)
+func (v Vocabulary) Canonical(source, label string) (opType string, known bool) {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" {
+		return "", true
+	}
+	if opType, found := v[source][label]; found {
+		return opType, true
+	}
+	opType, found := v[SourceAll][label]
+	return opType, found
+}
@@ -0,0 +1,53 @@
+package opvocab
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestVocabulary_Canonical(t *testing.T) {
+	v := DefaultVocabulary()
+	for _, tc := range []struct {
+		source, label string
+		opType        string
+		known         bool
+	}{
+		{SourceROP, "EPISSURE", OpSplice, true},
+		{SourceBPE, " Attente ", OpWaiting, true},
+		{SourceZACable, "LOV", OpLove, true},
+		{SourceBPE, "LOV", "", false},
+		{SourceBPE, "", "", true},
+		{SourceROP, "DERIVATION", "", false},
+	} {
+		opType, known := v.Canonical(tc.source, tc.label)
+		if opType != tc.opType || known != tc.known {
+			t.Errorf("%s label '%s' : got '%s' (known %v) instead of '%s' (known %v)", tc.source, tc.label, opType, known, tc.opType, tc.known)
+		}
+	}
+}
+
+func TestLoadVocabulary(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "vocabulary.json")
+	if err := ioutil.WriteFile(file, []byte(`{"bpe": {"Soudure": "Epissure"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := LoadVocabulary(file)
+	if err != nil {
+		t.Fatalf("LoadVocabulary returned unexpected: %s", err.Error())
+	}
+	if opType, _ := v.Canonical(SourceBPE, "SOUDURE"); opType != OpSplice {
+		t.Errorf("unexpected 'SOUDURE' type '%s'", opType)
+	}
+	if opType, _ := v.Canonical(SourceBPE, "Passage"); opType != OpPassage {
+		t.Errorf("built-in synonyms should be kept")
+	}
+
+	if err := ioutil.WriteFile(file, []byte(`{"rop": {"DERIVATION": "Derivation"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadVocabulary(file); err == nil {
+		t.Errorf("LoadVocabulary should fail on unknown operation type")
+	}
+}
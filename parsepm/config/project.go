@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Project describes a multi-PM project (ex: a whole commune), as a list of worksite project files
+type Project struct {
+	Name      string   `json:"name" yaml:"name"`           // summary workbook prefix
+	Dir       string   `json:"dir" yaml:"dir"`             // base dir for worksite files and summary workbook (default to project file dir)
+	Worksites []string `json:"worksites" yaml:"worksites"` // worksite project files (JSON or YAML), one per PM
+}
+
+// LoadProject returns the Project described in given file (YAML if .yaml or .yml extension, JSON otherwise)
+func LoadProject(file string) (*Project, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Project{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, p)
+	default:
+		err = json.Unmarshal(content, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse project file '%s': %s", filepath.Base(file), err.Error())
+	}
+	if p.Dir == "" {
+		p.Dir = filepath.Dir(file)
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	return p, nil
+}
+
+// Path returns given file path, relative to Project Dir if not absolute
+func (p *Project) Path(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(p.Dir, file)
+}
+
+// LoadWorksites returns the receiver worksites, in declaration order. Worksite names must be unique, as they identify zones in project
+func (p *Project) LoadWorksites() ([]*Worksite, error) {
+	if len(p.Worksites) == 0 {
+		return nil, fmt.Errorf("no worksite defined")
+	}
+	res := []*Worksite{}
+	names := map[string]string{}
+	for _, file := range p.Worksites {
+		ws, err := LoadWorksite(p.Path(file))
+		if err != nil {
+			return nil, err
+		}
+		if prev, found := names[ws.Name]; found {
+			return nil, fmt.Errorf("worksite name '%s' is used by '%s' and '%s'", ws.Name, prev, file)
+		}
+		names[ws.Name] = file
+		res = append(res, ws)
+	}
+	return res, nil
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProject(t *testing.T) {
+	p, err := LoadProject(filepath.Join("example", "projects", "commune.yaml"))
+	if err != nil {
+		t.Fatalf("LoadProject returned unexpected: %s", err.Error())
+	}
+	if p.Name != "Commune" || p.Dir != filepath.Join("example", "projects") {
+		t.Errorf("unexpected project name '%s' or dir '%s'", p.Name, p.Dir)
+	}
+	wss, err := p.LoadWorksites()
+	if err != nil {
+		t.Fatalf("LoadWorksites returned unexpected: %s", err.Error())
+	}
+	if len(wss) != len(p.Worksites) {
+		t.Errorf("unexpected number of worksites %d", len(wss))
+	}
+
+	p.Worksites = append(p.Worksites, p.Worksites[0])
+	if _, err := p.LoadWorksites(); err == nil {
+		t.Errorf("LoadWorksites should fail on duplicate worksite name")
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
+	"gopkg.in/yaml.v2"
+)
+
+// Activities defines which activities are to be done on the worksite
+type Activities struct {
+	Pulling        bool `json:"pulling" yaml:"pulling"`
+	Junctions      bool `json:"junctions" yaml:"junctions"`
+	Eline          bool `json:"eline" yaml:"eline"`
+	OtherThanEline bool `json:"otherThanEline" yaml:"otherThanEline"`
+	Measurement    bool `json:"measurement" yaml:"measurement"`
+}
+
+// Worksite describes all the infos needed to process a PM worksite (formerly hard-coded in parsepm main)
+type Worksite struct {
+	Client  string `json:"client" yaml:"client"`
+	Manager string `json:"manager" yaml:"manager"`
+	SiteId  int    `json:"siteId" yaml:"siteId"`
+	Name    string `json:"name" yaml:"name"` // output files prefix (ex: SRO 52-001-128 => SRO 52-001-128_suivi.xlsx)
+
+	Dir                string   `json:"dir" yaml:"dir"` // base dir for all relative paths below (default to project file dir)
+	BPEDir             string   `json:"bpeDir" yaml:"bpeDir"`
+	BlobPattern        string   `json:"blobPattern" yaml:"blobPattern"`   // "easyfibre", "sogetrel" or any glob pattern
+	BPEInclude         []string `json:"bpeInclude" yaml:"bpeInclude"`     // BPE file glob patterns (BlobPattern if empty), patterns with a '/' match path relative to BPEDir
+	BPERecursive       bool     `json:"bpeRecursive" yaml:"bpeRecursive"` // if true, patterns without '/' match files in BPEDir sub dirs too
+	BPEExclude         []string `json:"bpeExclude" yaml:"bpeExclude"`     // glob patterns of files to be ignored in BPEDir
+	BPELayouts         []string `json:"bpeLayouts" yaml:"bpeLayouts"`     // built-in layout names or JSON layout files, in detection order (all built-in ones if empty)
+	BPEWorkers         int      `json:"bpeWorkers" yaml:"bpeWorkers"`     // max number of BPE files parsed concurrently (number of CPUs if 0)
+	ROPFile            string   `json:"ropFile" yaml:"ropFile"`
+	ROPLayout          string   `json:"ropLayout" yaml:"ropLayout"`                   // built-in layout name ("standard", "axians", "sogetrel") or JSON layout file
+	Cable94File        string   `json:"cable94File" yaml:"cable94File"`               // optional: activates Pulling infos
+	CableOptiqueC2File string   `json:"cableOptiqueC2File" yaml:"cableOptiqueC2File"` // optional
+	BoiteOptiqueD2File string   `json:"boiteOptiqueD2File" yaml:"boiteOptiqueD2File"` // optional
+	CoordinatesFile    string   `json:"coordinatesFile" yaml:"coordinatesFile"`       // optional: geocoding table (CSV), activates GeoJSON / KML export
+	StrictRop          bool     `json:"strictRop" yaml:"strictRop"`                   // stop ROP parsing on first fatal error
+	SuiviFile          string   `json:"suiviFile" yaml:"suiviFile"`                   // optional: suivi workbook to import field status from
+	MergeSuivi         bool     `json:"mergeSuivi" yaml:"mergeSuivi"`                 // keep field columns of already existing suivi workbook
+	StoreFile          string   `json:"storeFile" yaml:"storeFile"`                   // optional: zone store database file, parsed zones are saved in it per version
+	DrumsFile          string   `json:"drumsFile" yaml:"drumsFile"`                   // optional: cable drums inventory workbook, activates drums pulling plan
+	MaterialsFile      string   `json:"materialsFile" yaml:"materialsFile"`           // optional: material reference table (JSON), activates bill of materials
+	PlanTrips          bool     `json:"planTrips" yaml:"planTrips"`                   // plan field interventions and pre-fill suivi workbook N° Déplacement columns
+	BPUFile            string   `json:"bpuFile" yaml:"bpuFile"`                       // optional: BPU workbook, planning throughputs are derived from its Work values
+	OperationsFile     string   `json:"operationsFile" yaml:"operationsFile"`         // optional: operation vocabulary (JSON), synonyms per source completing built-in ones
+
+	Activities         Activities        `json:"activities" yaml:"activities"`
+	LossBudget         node.LossBudget   `json:"lossBudget" yaml:"lossBudget"`                 // measurement loss hypothesis (missing fields keep default value)
+	Productivity       zone.Productivity `json:"productivity" yaml:"productivity"`             // field teams throughput used by planning (missing fields keep default value)
+	EnableDestBPECable map[string]string `json:"enableDestBPECable" yaml:"enableDestBPECable"` // ex: "ELINE": "CABLE_%dFO_IMMEUBLE_M6_G657A2"
+}
+
+// NewWorksite returns a Worksite with default activities
+func NewWorksite() *Worksite {
+	return &Worksite{
+		Activities: Activities{
+			Pulling:        false,
+			Junctions:      true,
+			Eline:          true,
+			OtherThanEline: true,
+			Measurement:    true,
+		},
+		LossBudget:         node.DefaultLossBudget(),
+		Productivity:       zone.DefaultProductivity(),
+		EnableDestBPECable: map[string]string{},
+	}
+}
+
+// LoadWorksite returns the Worksite described in given project file (YAML if .yaml or .yml extension, JSON otherwise)
+func LoadWorksite(file string) (*Worksite, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	ws := NewWorksite()
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, ws)
+	default:
+		err = json.Unmarshal(content, ws)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse project file '%s': %s", filepath.Base(file), err.Error())
+	}
+	if ws.Dir == "" {
+		ws.Dir = filepath.Dir(file)
+	}
+	return ws, nil
+}
+
+// Check returns an error if mandatory infos are missing
+func (ws *Worksite) Check() error {
+	switch {
+	case ws.Name == "":
+		return fmt.Errorf("name is not defined")
+	case ws.BPEDir == "":
+		return fmt.Errorf("bpeDir is not defined")
+	case ws.SiteId <= 0:
+		return fmt.Errorf("siteId is not defined")
+	}
+	return nil
+}
+
+// Path returns given file path, relative to Worksite Dir if not absolute (empty string if file is empty)
+func (ws *Worksite) Path(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(ws.Dir, file)
+}
+
+// GetBlobPattern returns the BPE file glob pattern to use with zone.ParseBPEDir
+func (ws *Worksite) GetBlobPattern() string {
+	switch strings.ToLower(ws.BlobPattern) {
+	case "", "easyfibre":
+		return zone.Blobpattern_EasyFibre
+	case "sogetrel":
+		return zone.Blobpattern_Sogetrel
+	}
+	return ws.BlobPattern
+}
+
+// NewZone returns a new zone.Zone configured according to receiver Worksite activities and ROP layout
+func (ws *Worksite) NewZone() (*zone.Zone, error) {
+	ropLayout := ws.ROPLayout
+	if strings.ToLower(filepath.Ext(ropLayout)) == ".json" {
+		ropLayout = ws.Path(ropLayout)
+	}
+	layout, err := zone.GetRopLayout(ropLayout)
+	if err != nil {
+		return nil, err
+	}
+
+	z := zone.New()
+	z.RopLayout = layout
+	for _, bpeLayout := range ws.BPELayouts {
+		if strings.ToLower(filepath.Ext(bpeLayout)) == ".json" {
+			bpeLayout = ws.Path(bpeLayout)
+		}
+		bl, err := node.GetBPELayout(bpeLayout)
+		if err != nil {
+			return nil, err
+		}
+		z.BPELayouts = append(z.BPELayouts, bl)
+	}
+	z.DoPulling = ws.Activities.Pulling
+	z.DoJunctions = ws.Activities.Junctions
+	z.DoEline = ws.Activities.Eline
+	z.DoOtherThanEline = ws.Activities.OtherThanEline
+	z.DoMeasurement = ws.Activities.Measurement
+	z.BlobPattern = ws.GetBlobPattern()
+	z.BPEIncludes = ws.BPEInclude
+	z.BPEExcludes = ws.BPEExclude
+	z.BPERecursive = ws.BPERecursive
+	z.StrictRop = ws.StrictRop
+	z.BPEWorkers = ws.BPEWorkers
+	z.LossBudget = ws.LossBudget
+	z.Productivity = ws.Productivity
+	if ws.BPUFile != "" {
+		z.Productivity, err = zone.ProductivityFromBPU(ws.Path(ws.BPUFile), ws.Productivity)
+		if err != nil {
+			return nil, fmt.Errorf("could not read BPU file: %s", err.Error())
+		}
+	}
+	if ws.MaterialsFile != "" {
+		z.MaterialTable, err = zone.LoadMaterialTable(ws.Path(ws.MaterialsFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if ws.OperationsFile != "" {
+		z.Vocabulary, err = opvocab.LoadVocabulary(ws.Path(ws.OperationsFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return z, nil
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
+)
+
+func TestLoadWorksite(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("example", "*.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		ws, err := LoadWorksite(f)
+		if err != nil {
+			t.Errorf("LoadWorksite returned unexpected: %s", err.Error())
+			continue
+		}
+		if err := ws.Check(); err != nil {
+			t.Errorf("'%s' is not a valid worksite: %s", filepath.Base(f), err.Error())
+		}
+		if !ws.Activities.Junctions {
+			t.Errorf("'%s': junctions activity should be enabled", filepath.Base(f))
+		}
+	}
+}
+
+func TestWorksite_GetBlobPattern(t *testing.T) {
+	ws := NewWorksite()
+	if ws.GetBlobPattern() != zone.Blobpattern_EasyFibre {
+		t.Errorf("default blob pattern should be EasyFibre one")
+	}
+	ws.BlobPattern = "Sogetrel"
+	if ws.GetBlobPattern() != zone.Blobpattern_Sogetrel {
+		t.Errorf("unexpected blob pattern '%s'", ws.GetBlobPattern())
+	}
+}
+
+func TestWorksite_NewZone(t *testing.T) {
+	ws := NewWorksite()
+	ws.Dir = "example"
+	ws.ROPLayout = "layouts/custom_rop.json"
+	ws.BPELayouts = []string{"layouts/custom_bpe.json", "standard"}
+	ws.MaterialsFile = "materials/materiel.json"
+	ws.OperationsFile = "operations/vocabulary.json"
+	z, err := ws.NewZone()
+	if err != nil {
+		t.Fatalf("NewZone returned unexpected: %s", err.Error())
+	}
+	if z.RopLayout.SheetPrefix != "ROP" || z.RopLayout.ColFirstChild != 7 || z.RopLayout.BlockNext != 8 {
+		t.Errorf("unexpected custom ROP layout: %+v", z.RopLayout)
+	}
+	if len(z.BPELayouts) != 2 || z.BPELayouts[0].Name != "custom" || z.BPELayouts[0].ColCableNameOut != 25 || z.BPELayouts[0].ColOperation != 13 {
+		t.Errorf("unexpected BPE layouts: %+v", z.BPELayouts)
+	}
+	if box := z.MaterialTable.Boxes["TENIO T1"]; box.Cassette != "CASS-12" || box.SplicesPerCassette != 12 {
+		t.Errorf("unexpected material table: %+v", z.MaterialTable)
+	}
+	if opType, known := z.Vocabulary.Canonical(opvocab.SourceBPE, "Epi."); !known || opType != opvocab.OpSplice {
+		t.Errorf("unexpected vocabulary: %+v", z.Vocabulary)
+	}
+	if zone.DefaultRopLayout().SheetPrefix != "TAB" {
+		t.Errorf("loading custom layout should not alter default one")
+	}
+}
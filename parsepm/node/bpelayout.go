@@ -0,0 +1,157 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+// HeaderCell describes a cell whose value identifies a splice plan template
+type HeaderCell struct {
+	Row    int    `json:"row"`
+	Col    int    `json:"col"`
+	Prefix string `json:"prefix"` // expected value prefix (case insensitive)
+}
+
+// BPELayout describes a BPE splice plan template : sheet selection, header cells and fiber rows columns
+type BPELayout struct {
+	Name        string       `json:"name"`
+	SheetPrefix string       `json:"sheetPrefix"` // splice plan sheet is the first one having name starting with SheetPrefix
+	Signature   []HeaderCell `json:"signature"`   // cells to be matched for the template to be detected
+
+	RowPtName  int `json:"rowPtName"`
+	ColPtName  int `json:"colPtName"`
+	RowBPEType int `json:"rowBPEType"`
+	ColBPEType int `json:"colBPEType"`
+	RowAddress int `json:"rowAddress"`
+	ColAddress int `json:"colAddress"`
+
+	RowFirstFiber   int `json:"rowFirstFiber"`
+	ColFiberNumIn   int `json:"colFiberNumIn"`
+	ColFiberNumOut  int `json:"colFiberNumOut"`
+	ColCableNameIn  int `json:"colCableNameIn"`
+	ColCableNameOut int `json:"colCableNameOut"`
+	ColOperation    int `json:"colOperation"`
+	ColTubulure     int `json:"colTubulure"` // output fiber tube (and troncon list block marker)
+	ColTubeIn       int `json:"colTubeIn"`   // input fiber tube (-1 if not available)
+
+	// Troncon list block starts with a ColTubulure value prefixed by CableDictMarker, and lists troncons (ex: "144 FO-CABLE 1") in ColCableDict from CableDictSkip rows below
+	CableDictMarker string `json:"cableDictMarker"`
+	CableDictSkip   int    `json:"cableDictSkip"`
+	ColCableDict    int    `json:"colCableDict"`
+}
+
+// standardBPELayout is the splice plan template handled so far
+var standardBPELayout = BPELayout{
+	Name:        "standard",
+	SheetPrefix: "Plan ",
+	Signature:   []HeaderCell{{Row: 1, Col: 8, Prefix: "PT"}}, // PT name header cell (ex: PT 182002)
+
+	RowPtName:  1,
+	ColPtName:  8,
+	RowBPEType: 4,
+	ColBPEType: 1,
+	RowAddress: 2,
+	ColAddress: 8,
+
+	RowFirstFiber:   9,
+	ColFiberNumIn:   11,
+	ColFiberNumOut:  19,
+	ColCableNameIn:  3,
+	ColCableNameOut: 24,
+	ColOperation:    13,
+	ColTubulure:     17,
+	ColTubeIn:       -1,
+
+	CableDictMarker: "Affectation des",
+	CableDictSkip:   2,
+	ColCableDict:    18,
+}
+
+// BPELayouts lists built-in splice plan templates, in detection order (most specific first)
+var BPELayouts = []BPELayout{
+	standardBPELayout,
+}
+
+// clone returns a copy of the receiver layout, not sharing its signature
+func (bl BPELayout) clone() BPELayout {
+	bl.Signature = append([]HeaderCell{}, bl.Signature...)
+	return bl
+}
+
+// GetBPELayout returns the built-in layout having given name, or loads it from given file if name is a JSON file path.
+//
+// Fields missing in a layout file keep standard layout value
+func GetBPELayout(name string) (BPELayout, error) {
+	for _, layout := range BPELayouts {
+		if strings.EqualFold(layout.Name, name) {
+			return layout.clone(), nil
+		}
+	}
+	if strings.ToLower(filepath.Ext(name)) != ".json" {
+		return BPELayout{}, fmt.Errorf("unknown BPE layout '%s'", name)
+	}
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return BPELayout{}, err
+	}
+	layout := standardBPELayout
+	layout.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	layout.Signature = nil
+	err = json.Unmarshal(content, &layout)
+	if err != nil {
+		return BPELayout{}, fmt.Errorf("could not parse BPE layout file '%s': %s", filepath.Base(name), err.Error())
+	}
+	return layout, nil
+}
+
+// GetSheet returns the receiver layout splice plan sheet from given file, or nil if file does not match layout
+func (bl BPELayout) GetSheet(xls *xlsx.File) *xlsx.Sheet {
+sheets:
+	for _, sheet := range xls.Sheets {
+		if !strings.HasPrefix(sheet.Name, bl.SheetPrefix) {
+			continue
+		}
+		for _, hc := range bl.Signature {
+			value := strings.ToLower(strings.TrimSpace(sheet.Cell(hc.Row, hc.Col).Value))
+			if !strings.HasPrefix(value, strings.ToLower(hc.Prefix)) {
+				continue sheets
+			}
+		}
+		return sheet
+	}
+	return nil
+}
+
+// ErrNoBPELayout is returned (wrapped) by DetectBPELayout when given file is not a splice plan workbook
+var ErrNoBPELayout = errors.New("no splice plan layout")
+
+// ErrBPEHeader is returned (wrapped) by DetectBPELayout when given file has a splice plan sheet, but its header does not match the layout signature
+var ErrBPEHeader = errors.New("splice plan header not recognized")
+
+// DetectBPELayout returns the first given layout (built-in BPELayouts if none given) matching the given file, and its related sheet
+func DetectBPELayout(xls *xlsx.File, layouts ...BPELayout) (BPELayout, *xlsx.Sheet, error) {
+	if len(layouts) == 0 {
+		layouts = BPELayouts
+	}
+	for _, layout := range layouts {
+		if sheet := layout.GetSheet(xls); sheet != nil {
+			return layout.clone(), sheet, nil
+		}
+	}
+	sheetNames := []string{}
+	for _, sheet := range xls.Sheets {
+		sheetNames = append(sheetNames, sheet.Name)
+		for _, layout := range layouts {
+			if strings.HasPrefix(sheet.Name, layout.SheetPrefix) {
+				return BPELayout{}, nil, fmt.Errorf("%w in sheet '%s' (layout '%s')", ErrBPEHeader, sheet.Name, layout.Name)
+			}
+		}
+	}
+	return BPELayout{}, nil, fmt.Errorf("%w matching sheet(s) '%s'", ErrNoBPELayout, strings.Join(sheetNames, "', '"))
+}
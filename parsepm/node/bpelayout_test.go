@@ -0,0 +1,114 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/tealeg/xlsx"
+)
+
+// newTestBPEFile returns a splice plan file using given layout, with one splice from 'CABLE IN' to 'CABLE OUT'
+func newTestBPEFile(t *testing.T, sheetName string, l BPELayout) *xlsx.File {
+	xf := xlsx.NewFile()
+	sheet, err := xf.AddSheet(sheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hc := range l.Signature {
+		sheet.Cell(hc.Row, hc.Col).SetString(hc.Prefix)
+	}
+	sheet.Cell(l.RowPtName, l.ColPtName).SetString("PT 1")
+	sheet.Cell(l.RowBPEType, l.ColBPEType).SetString("TENIO T1")
+	sheet.Cell(l.RowAddress, l.ColAddress).SetString("1 rue du Test")
+
+	row := l.RowFirstFiber
+	sheet.Cell(row, l.ColCableNameIn).SetString("CABLE IN")
+	sheet.Cell(row, l.ColFiberNumIn).SetString("1")
+	sheet.Cell(row, l.ColOperation).SetString("Epissure")
+	sheet.Cell(row, l.ColFiberNumOut).SetString("1")
+	sheet.Cell(row, l.ColCableNameOut).SetString("CABLE OUT")
+	row++
+	sheet.Cell(row, l.ColTubulure).SetString(l.CableDictMarker + " tubulures")
+	row += l.CableDictSkip + 1
+	sheet.Cell(row, l.ColCableDict).SetString("144 FO-CABLE IN")
+	sheet.Cell(row+1, l.ColCableDict).SetString("12 FO-CABLE OUT")
+	return xf
+}
+
+func TestNode_parseBPESheet(t *testing.T) {
+	xf := newTestBPEFile(t, "Plan PT 1", standardBPELayout)
+	layout, sheet, err := DetectBPELayout(xf)
+	if err != nil {
+		t.Fatalf("DetectBPELayout returned unexpected: %s", err.Error())
+	}
+	n := NewNode()
+	err = n.parseBPESheet(sheet, layout, NewTroncons(), opvocab.DefaultVocabulary())
+	if err != nil {
+		t.Fatalf("parseBPESheet returned unexpected: %s", err.Error())
+	}
+	if n.PtName != "PT 1" || n.BPEType != "TENIO T1" || n.LocationType != "BPE" {
+		t.Errorf("unexpected node info: %s %s %s", n.PtName, n.BPEType, n.LocationType)
+	}
+	if n.TronconIn.Name != "CABLE IN" || n.TronconIn.Capa != 144 || n.TronconsOut["CABLE OUT"].Capa != 12 {
+		t.Errorf("unexpected troncons info")
+	}
+	if n.Operation["Epissure->CABLE OUT"] != 1 {
+		t.Errorf("unexpected operations %v", n.Operation)
+	}
+	if len(n.Fibers) != 1 || n.Fibers[0].In() != "CABLE IN/1" || n.Fibers[0].Out() != "CABLE OUT/1" {
+		t.Fatalf("unexpected fiber operations %v", n.Fibers)
+	}
+	if counters := n.Fibers.Counters(); len(counters) != len(n.Operation) || counters["Epissure->CABLE OUT"] != 1 {
+		t.Errorf("operation counters %v differ from fiber level ones %v", n.Operation, counters)
+	}
+}
+
+func TestDetectBPELayout(t *testing.T) {
+	other := standardBPELayout
+	other.Name = "other"
+	other.SheetPrefix = "Boite "
+	other.Signature = []HeaderCell{{Row: 0, Col: 0, Prefix: "Plan de boite"}}
+	other.ColCableNameOut = 25
+
+	xf := newTestBPEFile(t, "Boite PT 1", other)
+	layout, _, err := DetectBPELayout(xf, standardBPELayout, other)
+	if err != nil {
+		t.Fatalf("DetectBPELayout returned unexpected: %s", err.Error())
+	}
+	if layout.Name != "other" {
+		t.Errorf("unexpected detected layout '%s'", layout.Name)
+	}
+
+	xf.Sheets[0].Cell(0, 0).SetString("Unknown template")
+	if _, _, err := DetectBPELayout(xf, standardBPELayout, other); err == nil {
+		t.Errorf("DetectBPELayout should fail on unmatched signature")
+	}
+
+	// a sheet matching sheet prefix but not signature does not hide the following ones
+	xf = xlsx.NewFile()
+	if _, err := xf.AddSheet("Plan de situation"); err != nil {
+		t.Fatal(err)
+	}
+	plan := newTestBPEFile(t, "Plan PT 1", standardBPELayout).Sheets[0]
+	if _, err := xf.AppendSheet(*plan, plan.Name); err != nil {
+		t.Fatal(err)
+	}
+	_, sheet, err := DetectBPELayout(xf)
+	if err != nil {
+		t.Fatalf("DetectBPELayout returned unexpected: %s", err.Error())
+	}
+	if sheet.Name != "Plan PT 1" {
+		t.Errorf("unexpected detected sheet '%s'", sheet.Name)
+	}
+}
+
+func TestGetBPELayout(t *testing.T) {
+	layout, err := GetBPELayout("Standard")
+	if err != nil {
+		t.Fatalf("GetBPELayout returned unexpected: %s", err.Error())
+	}
+	layout.Signature[0].Prefix = "altered"
+	if BPELayouts[0].Signature[0].Prefix != "PT" {
+		t.Errorf("altering returned layout should not alter built-in one")
+	}
+}
@@ -6,6 +6,8 @@ type Cable struct {
 	Capa     int
 	Length   int
 	Troncons []*Troncon
+
+	Status *FieldStatus // imported from suivi workbook (nil if not defined)
 }
 
 func NewCable(tr *Troncon) *Cable {
@@ -39,6 +41,24 @@ func (c *Cable) GetLenths() (lov, under, aer, fac int) {
 	return
 }
 
+// CableType returns the receiver cable reference (as defined on its first troncon)
+func (c *Cable) CableType() string {
+	if len(c.Troncons) == 0 {
+		return ""
+	}
+	return c.Troncons[0].CableType
+}
+
+// RequiredLength returns the cable length to be cut from a drum : pulled lengths (underground, aerial and facade) plus love lengths
+// when defined by quantity file, or estimated Length (distance plus default love length) otherwise
+func (c *Cable) RequiredLength() int {
+	lov, under, aer, fac := c.GetLenths()
+	if under+aer+fac > 0 {
+		return lov + under + aer + fac
+	}
+	return c.Length
+}
+
 const (
 	nbColTirage int = 11
 )
@@ -82,6 +102,7 @@ func (c *Cable) WriteTirageXLS(xs *xlsx.Sheet) {
 	r.AddCell().SetInt(under)
 	r.AddCell().SetInt(aer)
 	r.AddCell().SetInt(fac)
+	writeFieldStatus(r, c.Status)
 
 	color := colSouterrain
 	if (aer + fac) > 0 {
@@ -105,6 +126,7 @@ func (c *Cable) WriteTirageXLS(xs *xlsx.Sheet) {
 		r.AddCell().SetInt(tr.UndergroundLength)
 		r.AddCell().SetInt(tr.AerialLength)
 		r.AddCell().SetInt(tr.FacadeLength)
+		writeFieldStatus(r, tr.PullingStatus)
 
 		st := xlsx.NewStyle()
 		st.Font = *xlsx.NewFont(10, "Calibri")
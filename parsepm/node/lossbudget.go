@@ -0,0 +1,40 @@
+package node
+
+import "math"
+
+// LossBudget defines the optical loss hypothesis used to compute measured paths maximum acceptable loss
+type LossBudget struct {
+	Attenuation1310 float64 `json:"attenuation1310" yaml:"attenuation1310"` // fiber attenuation at 1310 nm (dB/km)
+	Attenuation1550 float64 `json:"attenuation1550" yaml:"attenuation1550"` // fiber attenuation at 1550 nm (dB/km)
+	SpliceLoss      float64 `json:"spliceLoss" yaml:"spliceLoss"`           // loss per splice (dB)
+	ConnectorLoss   float64 `json:"connectorLoss" yaml:"connectorLoss"`     // loss per connector (dB)
+	NbConnectors    int     `json:"nbConnectors" yaml:"nbConnectors"`       // number of connectors per path (PM drawer and attente)
+}
+
+// DefaultLossBudget returns the usual FTTH acceptance loss budget
+func DefaultLossBudget() LossBudget {
+	return LossBudget{
+		Attenuation1310: 0.35,
+		Attenuation1550: 0.25,
+		SpliceLoss:      0.15,
+		ConnectorLoss:   0.5,
+		NbConnectors:    2,
+	}
+}
+
+// MaxLoss returns the maximum acceptable loss (dB) at 1310 and 1550 nm of a path having given length (m) and number of splices
+func (lb LossBudget) MaxLoss(dist, nbSplice int) (loss1310, loss1550 float64) {
+	fixed := float64(nbSplice)*lb.SpliceLoss + float64(lb.NbConnectors)*lb.ConnectorLoss
+	loss1310 = roundLoss(float64(dist)/1000*lb.Attenuation1310 + fixed)
+	loss1550 = roundLoss(float64(dist)/1000*lb.Attenuation1550 + fixed)
+	return
+}
+
+// NodeMaxLoss returns the maximum acceptable loss (dB) at 1310 and 1550 nm of the paths measured from PM to given node
+func (lb LossBudget) NodeMaxLoss(n *Node) (loss1310, loss1550 float64) {
+	return lb.MaxLoss(n.DistFromPM, len(n.SplicePT))
+}
+
+func roundLoss(loss float64) float64 {
+	return math.Round(loss*100) / 100
+}
@@ -2,6 +2,8 @@ package node
 
 import (
 	"fmt"
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/xlsreader"
 	"github.com/tealeg/xlsx"
 	"sort"
 	"strconv"
@@ -15,10 +17,15 @@ type Node struct {
 	LocationType string // Chambre Orange (??)
 	Address      string // 0, FERME DU TOUPET AZOUDANGE (2,8)
 	DistFromPM   int
+	Lat, Long    float64 // WGS84 coordinates, from D2 file or geocoding table (0 if unknown)
 
 	TronconIn   *Troncon
 	TronconsOut Troncons
-	Operation   map[string]int
+	Operation   map[string]int // number of operations per key (ex: "Epissure->CABLE 2", "Attente")
+	Fibers      Operations     // fiber level operations, as read in splice plan (Operation counters are derived from it)
+
+	UnknownOperations map[string]int // number of fibers per operation label not found in vocabulary (not counted in Operation)
+	Stock             FiberStock     // reserved, loved and stored fibers (not counted in Operation)
 
 	StartDrawer string
 	EndDrawer   string
@@ -26,6 +33,12 @@ type Node struct {
 
 	Children []*Node
 	IsChild  bool
+
+	JunctionStatus    *FieldStatus // imported from suivi workbook (nil if not defined)
+	MeasurementStatus *FieldStatus // imported from suivi workbook (nil if not defined)
+
+	JunctionReadiness    Readiness // computed by zone, from incoming troncon pulling status
+	MeasurementReadiness Readiness // computed by zone, from SplicePT nodes junction status
 }
 
 func NewNode() *Node {
@@ -50,7 +63,7 @@ func NewPMNode(child *Node) *Node {
 		child.IsChild = true
 
 		tronconIn := NewTroncon("")
-		pm.Operation["Epissure->"+child.TronconIn.Name] = child.TronconIn.Capa
+		pm.Operation[opvocab.OpSplice+"->"+child.TronconIn.Name] = child.TronconIn.Capa
 		pm.TronconIn = tronconIn
 		pm.TronconsOut[""] = tronconIn
 		tronconOut := NewTroncon(child.TronconIn.Name)
@@ -69,6 +82,11 @@ func (n *Node) SetLocationType() {
 	n.LocationType = "PBO"
 }
 
+// IsLocated returns true if receiver node coordinates are known
+func (n *Node) IsLocated() bool {
+	return n.Lat != 0 || n.Long != 0
+}
+
 // GetToBeMeasuredFiber returns number of measurement to be done on receiver node
 //
 // - for PM node : number of "Epissure" Operation
@@ -79,30 +97,57 @@ func (n *Node) GetToBeMeasuredFiber() int {
 		return 0
 	}
 	if n.LocationType == "PM" {
-		return n.Operation["Epissure"]
+		return n.Operation[opvocab.OpSplice]
 	}
-	return n.Operation["Attente"]
+	return n.Operation[opvocab.OpWaiting]
 }
 
+// AddOperation increments receiver Operation counter related to given canonical operation type (used when no fiber level info is available).
+// Love operations are counted in receiver Stock
 func (n *Node) AddOperation(tronconIn, ope, fiberOut, tronconOut string) {
-	if ope == "Love" || ope == "" {
+	if ope == opvocab.OpLove {
+		n.Stock.Loved++
 		return
 	}
-
-	key := strings.Title(strings.ToLower(ope))
-	if fiberOut != "" {
-		if tronconIn == "" {
-			key += "<-" + tronconOut
-		} else {
-			key += "->" + tronconOut
-		}
+	key := Operation{Type: ope, CableIn: tronconIn, FiberOut: fiberOut, CableOut: tronconOut}.Key()
+	if key == "" {
+		return
 	}
 	n.Operation[key]++
 }
 
+// AddFiber adds given fiber level operation to receiver node, and increments related Operation counter.
+//
+// Operation type is set from its label according to given vocabulary. Operations with unknown label are not counted, but tallied in UnknownOperations.
+// Love operations are counted in receiver Stock
+func (n *Node) AddFiber(op *Operation, vocab opvocab.Vocabulary) {
+	opType, known := vocab.Canonical(opvocab.SourceBPE, op.Label)
+	op.Type = opType
+	n.Fibers = append(n.Fibers, op)
+	if !known {
+		n.AddUnknownOperation(op.Label, 1)
+		return
+	}
+	if opType == opvocab.OpLove {
+		n.Stock.Loved++
+		return
+	}
+	if key := op.Key(); key != "" {
+		n.Operation[key]++
+	}
+}
+
+// AddNbOperations adds nb operations of given canonical type to given output troncon
 func (n *Node) AddNbOperations(ope, tronconOut string, nb int) {
-	key := strings.Title(strings.ToLower(ope)) + "->" + tronconOut
-	n.Operation[key] += nb
+	n.Operation[ope+"->"+tronconOut] += nb
+}
+
+// AddUnknownOperation tallies nb fibers having given operation label, not found in vocabulary
+func (n *Node) AddUnknownOperation(label string, nb int) {
+	if n.UnknownOperations == nil {
+		n.UnknownOperations = map[string]int{}
+	}
+	n.UnknownOperations[label] += nb
 }
 
 func (n *Node) AddChild(cn *Node) {
@@ -177,9 +222,8 @@ func (n *Node) GetNumbers() (nbEpi, nbOther int) {
 }
 
 func (n *Node) GetOperationNumbers(ope string) (nbEpi, nbOther int) {
-	lope := strings.ToLower(ope)
-	switch {
-	case strings.HasPrefix(lope, "epissure"):
+	switch OperationKeyType(ope) {
+	case opvocab.OpSplice:
 		nbEpi += n.Operation[ope]
 	default:
 		nbOther += n.Operation[ope]
@@ -187,46 +231,34 @@ func (n *Node) GetOperationNumbers(ope string) (nbEpi, nbOther int) {
 	return
 }
 
-const (
-	rowBpePtName = 1
-	colBpePtName = 8
-	rowBPEType   = 4
-	colBPEType   = 1
-	rowAddress   = 2
-	colAddress   = 8
-
-	colFiberNumIn   = 11
-	colFiberNumOut  = 19
-	colCableNameIn  = 3
-	colCableNameOut = 24
-	colOperation    = 13
-	colTubulure     = 17
-	colCableDict    = 18
-)
-
-func (n *Node) ParseBPEXLS(file string, troncons Troncons) error {
-	xls, err := xlsx.OpenFile(file)
+// ParseBPEXLS populates receiver node with given BPE splice plan file (.xlsx, or legacy .xls) info. Splice plan template is detected among given layouts (built-in BPELayouts if none given).
+// Operation labels are mapped to canonical types with given vocabulary
+func (n *Node) ParseBPEXLS(file string, troncons Troncons, vocab opvocab.Vocabulary, layouts ...BPELayout) error {
+	xls, err := xlsreader.OpenFile(file)
 	if err != nil {
 		return err
 	}
 
-	sheet := xls.Sheets[0]
-	if !strings.HasPrefix(sheet.Name, "Plan ") {
-		return fmt.Errorf("Unexpected Sheet name: '%s'", sheet.Name)
+	layout, sheet, err := DetectBPELayout(xls, layouts...)
+	if err != nil {
+		return err
 	}
+	return n.parseBPESheet(sheet, layout, troncons, vocab)
+}
 
+func (n *Node) parseBPESheet(sheet *xlsx.Sheet, layout BPELayout, troncons Troncons, vocab opvocab.Vocabulary) error {
 	// n.Name
-	n.PtName = sheet.Cell(rowBpePtName, colBpePtName).Value
-	n.BPEType = sheet.Cell(rowBPEType, colBPEType).Value
+	n.PtName = sheet.Cell(layout.RowPtName, layout.ColPtName).Value
+	n.BPEType = sheet.Cell(layout.RowBPEType, layout.ColBPEType).Value
 	// n.LocationType
-	n.Address = sheet.Cell(rowAddress, colAddress).Value
+	n.Address = sheet.Cell(layout.RowAddress, layout.ColAddress).Value
 
 	var tronconIn, tronconOut string
 	var CableDictZone bool
 	// Scan all fiber info rows
-	for row := 9; row < sheet.MaxRow; row++ {
+	for row := layout.RowFirstFiber; row < sheet.MaxRow; row++ {
 		if CableDictZone {
-			tronconInfo := sheet.Cell(row, colCableDict).Value
+			tronconInfo := sheet.Cell(row, layout.ColCableDict).Value
 			if tronconInfo == "" {
 				continue
 			}
@@ -252,12 +284,12 @@ func (n *Node) ParseBPEXLS(file string, troncons Troncons) error {
 			}
 			continue
 		}
-		fiberIn := sheet.Cell(row, colFiberNumIn).Value
-		fiberOut := sheet.Cell(row, colFiberNumOut).Value
-		ope := sheet.Cell(row, colOperation).Value
-		nTronconIn := sheet.Cell(row, colCableNameIn).Value
+		fiberIn := sheet.Cell(row, layout.ColFiberNumIn).Value
+		fiberOut := sheet.Cell(row, layout.ColFiberNumOut).Value
+		ope := sheet.Cell(row, layout.ColOperation).Value
+		nTronconIn := sheet.Cell(row, layout.ColCableNameIn).Value
 		if nTronconIn != "" && tronconIn != nTronconIn {
-			if !(ope == "Love" && fiberIn != "" && fiberOut != "") {
+			if opType, _ := vocab.Canonical(opvocab.SourceBPE, ope); !(opType == opvocab.OpLove && fiberIn != "" && fiberOut != "") {
 				if n.TronconIn != nil {
 					return fmt.Errorf("multiple Troncon In found line %d : %s", row+1, nTronconIn)
 				}
@@ -266,7 +298,7 @@ func (n *Node) ParseBPEXLS(file string, troncons Troncons) error {
 				n.TronconIn.NodeDest = n
 			}
 		}
-		nTronconOut := sheet.Cell(row, colCableNameOut).Value
+		nTronconOut := sheet.Cell(row, layout.ColCableNameOut).Value
 		if nTronconOut != "" && tronconOut != nTronconOut {
 			tronconOut = nTronconOut
 			tro := troncons.Get(tronconOut)
@@ -274,15 +306,27 @@ func (n *Node) ParseBPEXLS(file string, troncons Troncons) error {
 			n.TronconsOut[tronconOut] = tro
 		}
 
+		// detect "Affectation des tubulures" blocks (troncon list)
+		tube := sheet.Cell(row, layout.ColTubulure).Value
+
 		if fiberIn != "" || fiberOut != "" { // Input or Output Troncon info available, process it
-			n.AddOperation(tronconIn, ope, fiberOut, tronconOut)
+			op := &Operation{
+				Label:    ope,
+				CableIn:  tronconIn,
+				FiberIn:  strings.TrimSpace(fiberIn),
+				CableOut: tronconOut,
+				TubeOut:  strings.TrimSpace(tube),
+				FiberOut: strings.TrimSpace(fiberOut),
+			}
+			if layout.ColTubeIn >= 0 {
+				op.TubeIn = strings.TrimSpace(sheet.Cell(row, layout.ColTubeIn).Value)
+			}
+			n.AddFiber(op, vocab)
 		}
 
-		// detect "Affectation des tubulures" blocks (troncon list)
-		tube := sheet.Cell(row, colTubulure).Value
-		if strings.HasPrefix(tube, "Affectation des") {
+		if strings.HasPrefix(tube, layout.CableDictMarker) {
 			CableDictZone = true
-			row += 2
+			row += layout.CableDictSkip
 		}
 	}
 	n.SetLocationType()
@@ -311,7 +355,7 @@ func (n *Node) GetOperationCapa(ope string) string {
 func (n *Node) SetOperationFromChildren() {
 	for _, cn := range n.Children {
 		n.TronconIn.Capa += cn.TronconIn.Capa
-		key := "Epissure->" + cn.TronconIn.Name
+		key := opvocab.OpSplice + "->" + cn.TronconIn.Name
 		n.Operation[key] = cn.TronconIn.Capa
 	}
 }
@@ -334,8 +378,8 @@ func (n *Node) SetSplicePTs(splicePT ...string) {
 func (n *Node) getSplicedChildren() map[string]bool {
 	res := map[string]bool{}
 	for ope, _ := range n.Operation {
-		if strings.HasPrefix(ope, "Epissure->") {
-			dest := strings.TrimPrefix(ope, "Epissure->")
+		if strings.HasPrefix(ope, opvocab.OpSplice+"->") {
+			dest := strings.TrimPrefix(ope, opvocab.OpSplice+"->")
 			if dest != "" {
 				tronconDest, found := n.TronconsOut[dest]
 				if !found {
@@ -353,8 +397,8 @@ func (n *Node) getSplicedChildren() map[string]bool {
 func (n *Node) SpliceTRs() []*Troncon {
 	res := []*Troncon{}
 	for ope, _ := range n.Operation {
-		if strings.HasPrefix(ope, "Epissure->") {
-			res = append(res, n.TronconsOut[strings.TrimPrefix(ope, "Epissure->")])
+		if strings.HasPrefix(ope, opvocab.OpSplice+"->") {
+			res = append(res, n.TronconsOut[strings.TrimPrefix(ope, opvocab.OpSplice+"->")])
 		}
 	}
 	return res
@@ -365,8 +409,8 @@ func (n *Node) SpliceTRs() []*Troncon {
 // (unicity is not checked, first troncon found will be returned)
 func (n *Node) GetTronconPassage() *Troncon {
 	for ope, _ := range n.Operation {
-		if strings.HasPrefix(ope, "Passage->") {
-			return n.TronconsOut[strings.TrimPrefix(ope, "Passage->")]
+		if strings.HasPrefix(ope, opvocab.OpPassage+"->") {
+			return n.TronconsOut[strings.TrimPrefix(ope, opvocab.OpPassage+"->")]
 		}
 	}
 	return nil
@@ -390,6 +434,12 @@ func (n *Node) WriteRaccoHeader(xs *xlsx.Sheet) {
 		{"N° Déplacement", 15},
 		{"Début", 15},
 		{"Fin", 15},
+
+		{"Disponibilité", 30},
+
+		{"Nb Réserve", 12},
+		{"Nb Love", 12},
+		{"Nb Stockée", 12},
 	}
 	addHeaderRow(xs, cols)
 }
@@ -404,9 +454,12 @@ const (
 	colImmeuble     string = "ffe4dfec"
 	coldefault      string = "ffff8800"
 	colError        string = "ffff0000"
+	colReady        string = "ffc6efce"
+	colBlocked      string = "ffffc7ce"
 
-	nbColRacco   int = 10
-	nbColMeasure int = 6
+	nbColRacco    int = 19 // site rows : info, field status, readiness and fiber stock columns
+	nbColRaccoOpe int = 10 // operation rows : info columns only
+	nbColMeasure  int = 6
 )
 
 func (n *Node) WriteRaccoXLS(xs *xlsx.Sheet) {
@@ -425,7 +478,7 @@ func (n *Node) WriteRaccoXLS(xs *xlsx.Sheet) {
 		st.Font = *xlsx.NewFont(10, "Calibri")
 		st.Font.Color = "FF6F6F6F"
 		st.ApplyFont = true
-		addStyleOnRow(r, st, nbColRacco)
+		addStyleOnRow(r, st, nbColRaccoOpe)
 	}
 
 	for _, cnode := range n.GetChildren() {
@@ -433,16 +486,21 @@ func (n *Node) WriteRaccoXLS(xs *xlsx.Sheet) {
 	}
 }
 
-func (n *Node) writeSiteRaccoInfo(r *xlsx.Row) {
-	epi, other := n.GetNumbers()
-	color := colBPE
+// RaccoColor returns the receiver Racco sheet color (ARGB), depending on its LocationType (PM, BPE or PBO)
+func (n *Node) RaccoColor() string {
 	//locType := strings.ToLower(strings.TrimSpace(n.LocationType))
 	switch n.LocationType {
 	case "PM":
-		color = colPM
+		return colPM
 	case "PBO":
-		color = colPBO
+		return colPBO
 	}
+	return colBPE
+}
+
+func (n *Node) writeSiteRaccoInfo(r *xlsx.Row) {
+	epi, other := n.GetNumbers()
+	color := n.RaccoColor()
 
 	r.AddCell().SetString(n.PtName)
 	r.AddCell().SetString(n.Address)
@@ -454,11 +512,16 @@ func (n *Node) writeSiteRaccoInfo(r *xlsx.Row) {
 	r.AddCell().SetString("TOTAL")
 	r.AddCell().SetInt(other + epi)
 	r.AddCell().SetInt(epi)
+	writeFieldStatus(r, n.JunctionStatus)
+	rdCell := writeReadiness(r, n.JunctionReadiness)
+	writeFiberStock(r, n.Stock)
 
 	st := xlsx.NewStyle()
 	st.Fill = *xlsx.NewFill("solid", color, "00000000")
 	st.ApplyFill = true
 	addStyleOnRow(r, st, nbColRacco)
+	// readiness cell keeps its own color
+	setReadinessStyle(rdCell, n.JunctionReadiness)
 }
 
 func (n *Node) WriteMesuresHeader(xs *xlsx.Sheet) {
@@ -475,14 +538,20 @@ func (n *Node) WriteMesuresHeader(xs *xlsx.Sheet) {
 		{"N° Déplacement", 15},
 		{"Début", 15},
 		{"Fin", 15},
+
+		{"Perte max 1310 (dB)", 18},
+		{"Perte max 1550 (dB)", 18},
+
+		{"Disponibilité", 30},
 	}
 	addHeaderRow(xs, cols)
 }
 
-func (n *Node) WriteMesuresXLS(xs *xlsx.Sheet, nodes Nodes) {
+// WriteMesuresXLS writes receiver (and its children) measurement info rows, with maximum acceptable loss computed with given loss budget
+func (n *Node) WriteMesuresXLS(xs *xlsx.Sheet, nodes Nodes, budget LossBudget) {
 	wf := n.GetToBeMeasuredFiber()
 	if wf > 0 {
-		n.writeMesuresInfo(xs, wf)
+		n.writeMesuresInfo(xs, wf, budget)
 		for i, ptName := range n.SplicePT {
 			pt := nodes[ptName]
 			r := xs.AddRow()
@@ -501,11 +570,11 @@ func (n *Node) WriteMesuresXLS(xs *xlsx.Sheet, nodes Nodes) {
 	}
 
 	for _, cnode := range n.GetChildren() {
-		cnode.WriteMesuresXLS(xs, nodes)
+		cnode.WriteMesuresXLS(xs, nodes, budget)
 	}
 }
 
-func (n *Node) writeMesuresInfo(xs *xlsx.Sheet, nbWaiting int) {
+func (n *Node) writeMesuresInfo(xs *xlsx.Sheet, nbWaiting int, budget LossBudget) {
 	r := xs.AddRow()
 	r.AddCell().SetString(n.PtName)
 	r.AddCell().SetInt(nbWaiting)
@@ -513,9 +582,39 @@ func (n *Node) writeMesuresInfo(xs *xlsx.Sheet, nbWaiting int) {
 	r.AddCell().SetInt(n.DistFromPM)
 	r.AddCell().SetString(n.StartDrawer)
 	r.AddCell().SetString(n.EndDrawer)
+	writeFieldStatus(r, n.MeasurementStatus)
+	loss1310, loss1550 := budget.NodeMaxLoss(n)
+	r.AddCell().SetFloatWithFormat(loss1310, "0.00")
+	r.AddCell().SetFloatWithFormat(loss1550, "0.00")
+	writeReadiness(r, n.MeasurementReadiness)
 
 	st := xlsx.NewStyle()
 	st.Fill = *xlsx.NewFill("solid", colPM, "00000000")
 	st.ApplyFill = true
 	addStyleOnRow(r, st, nbColMeasure)
 }
+
+// writeReadiness adds a readiness cell to given row (see setReadinessStyle), and returns it
+func writeReadiness(r *xlsx.Row, rd Readiness) *xlsx.Cell {
+	cell := r.AddCell()
+	cell.SetString(rd.String())
+	setReadinessStyle(cell, rd)
+	return cell
+}
+
+// setReadinessStyle colors given readiness cell if work is ready or blocked
+func setReadinessStyle(cell *xlsx.Cell, rd Readiness) {
+	var color string
+	switch rd.State {
+	case ReadinessReady:
+		color = colReady
+	case ReadinessBlocked:
+		color = colBlocked
+	default:
+		return
+	}
+	st := xlsx.NewStyle()
+	st.Fill = *xlsx.NewFill("solid", color, "00000000")
+	st.ApplyFill = true
+	cell.SetStyle(st)
+}
@@ -3,6 +3,9 @@ package node
 import (
 	"path/filepath"
 	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/tealeg/xlsx"
 )
 
 const (
@@ -19,9 +22,130 @@ func TestNode_ParseXLS(t *testing.T) {
 	}
 	for _, f := range files {
 		n := NewNode()
-		err := n.ParseBPEXLS(f)
+		err := n.ParseBPEXLS(f, NewTroncons(), opvocab.DefaultVocabulary())
 		if err != nil {
 			t.Errorf("'%s' returned unexpected : %s\n", filepath.Base(f), err.Error())
 		}
 	}
 }
+
+func TestLossBudget_MaxLoss(t *testing.T) {
+	loss1310, loss1550 := DefaultLossBudget().MaxLoss(2000, 3)
+	if loss1310 != 2.15 || loss1550 != 1.95 {
+		t.Errorf("MaxLoss returned unexpected %.2f dB at 1310 nm and %.2f dB at 1550 nm", loss1310, loss1550)
+	}
+}
+
+func TestNode_FiberStock(t *testing.T) {
+	n := NewNode()
+	n.PtName, n.TronconIn = "PT 1", NewTroncon("CABLE IN")
+	n.TronconsOut["CABLE OUT"] = NewTroncon("CABLE OUT")
+	vocab := opvocab.DefaultVocabulary()
+	n.AddFiber(&Operation{Label: "Epissure", CableIn: "CABLE IN", FiberIn: "1", CableOut: "CABLE OUT", FiberOut: "1"}, vocab)
+	n.AddFiber(&Operation{Label: "Love", CableIn: "CABLE IN", FiberIn: "2", CableOut: "CABLE OUT", FiberOut: "2"}, vocab)
+	n.AddFiber(&Operation{Label: "Lovage", CableIn: "CABLE IN", FiberIn: "3"}, vocab)
+	n.AddOperation("", opvocab.OpLove, "", "")
+	n.Stock.Reserved = 2
+	if n.Stock.Loved != 3 || n.Stock.Total() != 5 || len(n.Operation) != 1 {
+		t.Errorf("unexpected stock %+v and operations %v", n.Stock, n.Operation)
+	}
+
+	xs, err := xlsx.NewFile().AddSheet("Racco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.JunctionReadiness = Readiness{State: ReadinessReady}
+	n.WriteRaccoHeader(xs)
+	n.WriteRaccoXLS(xs)
+	for c := range xs.Rows[0].Cells {
+		fill := xs.Cell(1, c).GetStyle().Fill.FgColor
+		if xs.Rows[0].Cells[c].Value == "Disponibilité" {
+			if fill != colReady {
+				t.Errorf("readiness cell should keep its color")
+			}
+			continue
+		}
+		if fill != n.RaccoColor() {
+			t.Errorf("TOTAL row '%s' cell is not styled", xs.Rows[0].Cells[c].Value)
+		}
+	}
+	for col, expected := range map[string]string{"Nb Réserve": "2", "Nb Love": "3", "Nb Stockée": "0"} {
+		found := false
+		for c, cell := range xs.Rows[0].Cells {
+			if cell.Value == col {
+				found = true
+				if value := xs.Cell(1, c).Value; value != expected {
+					t.Errorf("%s is '%s' instead of '%s'", col, value, expected)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("missing Racco column '%s'", col)
+		}
+	}
+}
+
+func TestCable_WriteTirageXLS(t *testing.T) {
+	src, dst := NewNode(), NewNode()
+	src.PtName, dst.PtName = "PT 1", "PT 2"
+	tr := NewTroncon("CABLE 1")
+	tr.NodeSource, tr.NodeDest = src, dst
+	tr.PullingStatus = &FieldStatus{Label: "en cours", Actors: "Team A"}
+	c := NewCable(tr)
+	c.AddTroncon(tr, 0)
+
+	xs, err := xlsx.NewFile().AddSheet("Tirage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.WriteTirageXLS(xs)
+	if len(xs.Rows) != 2 {
+		t.Fatalf("unexpected %d rows", len(xs.Rows))
+	}
+	if label, actors := xs.Cell(1, 11).Value, xs.Cell(1, 12).Value; label != "en cours" || actors != "Team A" {
+		t.Errorf("troncon row pulling status not written: '%s' '%s'", label, actors)
+	}
+}
+
+func TestNode_TraceFiber(t *testing.T) {
+	vocab := opvocab.DefaultVocabulary()
+	pt1, pt2 := NewNode(), NewNode()
+	pt1.PtName, pt1.TronconIn = "PT 1", NewTroncon("CABLE 1")
+	pt2.PtName, pt2.TronconIn = "PT 2", NewTroncon("CABLE 2")
+	pt1.AddChild(pt2)
+	pt1.AddFiber(&Operation{Label: "Epissure", CableIn: "CABLE 1", FiberIn: "3", CableOut: "CABLE 2", TubeOut: "1", FiberOut: "5"}, vocab)
+	pt1.AddFiber(&Operation{Label: "Attente", CableIn: "CABLE 1", FiberIn: "4"}, vocab)
+	pt2.AddFiber(&Operation{Label: "Passage", CableIn: "CABLE 2", TubeIn: "2", FiberIn: "5", CableOut: "CABLE 3", TubeOut: "1", FiberOut: "1"}, vocab)
+	pt2.AddFiber(&Operation{Label: "Attente", CableIn: "CABLE 2", TubeIn: "1", FiberIn: "5"}, vocab)
+
+	hops := pt1.TraceFiber("CABLE 1", "", "3")
+	if len(hops) != 2 || hops[0].Node != pt1 || hops[1].Node != pt2 || hops[1].Operation.Type != opvocab.OpWaiting {
+		t.Errorf("TraceFiber returned unexpected %v", hops)
+	}
+	if hops := pt1.TraceFiber("CABLE 1", "", "4"); len(hops) != 1 || hops[0].Node != pt1 {
+		t.Errorf("TraceFiber returned unexpected %v", hops)
+	}
+	up := pt2.TraceFiberUp("CABLE 2", "1", "5")
+	if len(up) != 2 || up[0].Node != pt1 || up[0].Operation.FiberIn != "3" {
+		t.Errorf("TraceFiberUp returned unexpected %v", up)
+	}
+	if hops := pt1.TraceFiber("CABLE 1", "", "6"); len(hops) != 0 {
+		t.Errorf("TraceFiber returned unexpected %v on unknown fiber", hops)
+	}
+	// fiber numbers restart per tube : fiber can not be told apart without its tube
+	if hops := pt2.TraceFiber("CABLE 2", "2", "5"); len(hops) != 1 || hops[0].Operation.Type != opvocab.OpPassage {
+		t.Errorf("TraceFiber returned unexpected %v on tube 2 fiber", hops)
+	}
+	if hops := pt2.TraceFiber("CABLE 2", "", "5"); len(hops) != 0 {
+		t.Errorf("TraceFiber returned unexpected %v on ambiguous fiber", hops)
+	}
+}
+
+func TestOperationKey(t *testing.T) {
+	if key := "Passage->CABLE 2"; OperationKeyType(key) != opvocab.OpPassage || OperationKeyTroncon(key) != "CABLE 2" {
+		t.Errorf("unexpected '%s' key type or troncon", key)
+	}
+	if key := "Attente"; OperationKeyType(key) != opvocab.OpWaiting || OperationKeyTroncon(key) != "" {
+		t.Errorf("unexpected '%s' key type or troncon", key)
+	}
+}
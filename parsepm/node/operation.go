@@ -1,4 +1,212 @@
 package node
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+)
+
+// Operation describes what is done on one fiber in a node, as read on a splice plan fiber row :
+// fiber FiberIn of tube TubeIn of cable CableIn is spliced (Epissure), passed through (Passage) or left waiting (Attente)
+// to fiber FiberOut of tube TubeOut of cable CableOut
 type Operation struct {
+	Type     string // canonical operation type (opvocab.OpSplice, opvocab.OpPassage, opvocab.OpWaiting, opvocab.OpLove), empty if none or unknown
+	Label    string // operation as typed in splice plan
+	CableIn  string
+	TubeIn   string // empty if splice plan template has no input tube column
+	FiberIn  string // empty if none
+	CableOut string
+	TubeOut  string
+	FiberOut string // empty if none
+}
+
+// Key returns the receiver Node.Operation counter key (ex: "Epissure->CABLE 2", "Attente"), or "" if receiver is not counted (Love or no operation)
+func (o Operation) Key() string {
+	if o.Type == opvocab.OpLove || o.Type == "" {
+		return ""
+	}
+	key := o.Type
+	if o.FiberOut != "" {
+		if o.CableIn == "" {
+			key += "<-" + o.CableOut
+		} else {
+			key += "->" + o.CableOut
+		}
+	}
+	return key
+}
+
+// In returns the receiver input fiber reference (cable/tube/fiber)
+func (o Operation) In() string {
+	return fiberRef(o.CableIn, o.TubeIn, o.FiberIn)
+}
+
+// Out returns the receiver output fiber reference (cable/tube/fiber)
+func (o Operation) Out() string {
+	return fiberRef(o.CableOut, o.TubeOut, o.FiberOut)
+}
+
+func fiberRef(cable, tube, fiber string) string {
+	if fiber == "" {
+		return ""
+	}
+	if tube == "" {
+		return fmt.Sprintf("%s/%s", cable, fiber)
+	}
+	return fmt.Sprintf("%s/%s/%s", cable, tube, fiber)
+}
+
+func (o Operation) String() string {
+	label := o.Label
+	if label == "" {
+		label = o.Type
+	}
+	return fmt.Sprintf("%s %s -> %s", label, o.In(), o.Out())
+}
+
+// FiberStock counts the node fibers which are neither spliced nor left waiting for a client (clients pay for some of them, and audit the others)
+type FiberStock struct {
+	Reserved int `json:"reserved"` // fibers left waiting for a reserve route (ROP), unless counted as client attentes (CountReserveOR)
+	Loved    int `json:"loved"`    // fibers passed through the node without being cut (Love operation)
+	Stored   int `json:"stored"`   // fibers left waiting for a route neither client nor reserve (ROP)
+}
+
+// Total returns the receiver number of fibers
+func (fs FiberStock) Total() int {
+	return fs.Reserved + fs.Loved + fs.Stored
+}
+
+// Operations is a list of fiber level operations
+type Operations []*Operation
+
+// Counters returns the number of operations per Node.Operation key
+func (ops Operations) Counters() map[string]int {
+	res := map[string]int{}
+	for _, op := range ops {
+		if key := op.Key(); key != "" {
+			res[key]++
+		}
+	}
+	return res
+}
+
+// FromFiber returns the operation having given input fiber (nil if not found).
+//
+// Fiber numbers may restart per tube : tube is matched when both given tube and operation one are known, and an operation without tube info
+// is only returned if it is the only one having given cable and fiber number (nil is returned when the fiber can not be told apart)
+func (ops Operations) FromFiber(cable, tube, fiber string) *Operation {
+	return ops.findFiber(func(op *Operation) (string, string, string) { return op.CableIn, op.TubeIn, op.FiberIn }, cable, tube, fiber)
+}
+
+// ToFiber returns the operation having given output fiber (nil if not found). Tube is matched as in FromFiber
+func (ops Operations) ToFiber(cable, tube, fiber string) *Operation {
+	return ops.findFiber(func(op *Operation) (string, string, string) { return op.CableOut, op.TubeOut, op.FiberOut }, cable, tube, fiber)
+}
+
+// findFiber returns the operation whose fiber reference (as returned by ref) matches given cable, tube and fiber (see FromFiber)
+func (ops Operations) findFiber(ref func(op *Operation) (string, string, string), cable, tube, fiber string) *Operation {
+	candidates := Operations{}
+	for _, op := range ops {
+		opCable, opTube, opFiber := ref(op)
+		if opCable != cable || opFiber != fiber {
+			continue
+		}
+		if tube != "" && opTube != "" {
+			if opTube == tube {
+				return op
+			}
+			continue
+		}
+		candidates = append(candidates, op)
+	}
+	if len(candidates) != 1 {
+		return nil
+	}
+	return candidates[0]
+}
+
+// FiberHop is a node traversed by a fiber, along with the fiber level operation done on it
+type FiberHop struct {
+	Node      *Node
+	Operation *Operation
+}
+
+func (fh FiberHop) String() string {
+	return fmt.Sprintf("%s (%s)", fh.Node.PtName, fh.Operation.String())
+}
+
+// TraceFiber returns the nodes traversed by given input fiber (tube may be empty if unknown) of receiver node, following output fibers from node to child node (through their
+// input troncon) down to the node where fiber is left waiting (or where splice plan info stops). Returned list is empty if receiver has no such input fiber
+func (n *Node) TraceFiber(cable, tube, fiber string) []FiberHop {
+	res := []FiberHop{}
+	visited := map[*Node]bool{}
+	for cn := n; cn != nil && !visited[cn]; {
+		visited[cn] = true
+		op := cn.Fibers.FromFiber(cable, tube, fiber)
+		if op == nil {
+			break
+		}
+		res = append(res, FiberHop{Node: cn, Operation: op})
+		if op.FiberOut == "" {
+			break
+		}
+		cn, cable, tube, fiber = cn.childOn(op.CableOut), op.CableOut, op.TubeOut, op.FiberOut
+	}
+	return res
+}
+
+// TraceFiberUp returns the nodes traversed by given input fiber (tube may be empty if unknown) of receiver node from upstream, following input fibers from node to parent node
+// (through its input troncon) up to the first node having splice plan info. Returned list is ordered from upstream node down to receiver node,
+// and is empty if receiver has no such input fiber
+func (n *Node) TraceFiberUp(cable, tube, fiber string) []FiberHop {
+	op := n.Fibers.FromFiber(cable, tube, fiber)
+	if op == nil {
+		return []FiberHop{}
+	}
+	res := []FiberHop{{Node: n, Operation: op}}
+	visited := map[*Node]bool{n: true}
+	for cn := n; cn.TronconIn != nil && cn.TronconIn.NodeSource != nil; {
+		cn = cn.TronconIn.NodeSource
+		if visited[cn] {
+			break
+		}
+		visited[cn] = true
+		op = cn.Fibers.ToFiber(cable, tube, fiber)
+		if op == nil {
+			break
+		}
+		res = append([]FiberHop{{Node: cn, Operation: op}}, res...)
+		cable, tube, fiber = op.CableIn, op.TubeIn, op.FiberIn
+	}
+	return res
+}
+
+// childOn returns the receiver child node fed by given output troncon (nil if not found)
+func (n *Node) childOn(troncon string) *Node {
+	for _, cn := range n.Children {
+		if cn.TronconIn != nil && cn.TronconIn.Name == troncon {
+			return cn
+		}
+	}
+	return nil
+}
+
+// OperationKeyType returns the operation type of given Node.Operation key (ex: "Epissure" for "Epissure->CABLE 2")
+func OperationKeyType(key string) string {
+	if i := strings.Index(key, "->"); i >= 0 {
+		return key[:i]
+	}
+	if i := strings.Index(key, "<-"); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+// OperationKeyTroncon returns the output troncon name of given Node.Operation key (ex: "CABLE 2" for "Epissure->CABLE 2"), or "" if none
+func OperationKeyTroncon(key string) string {
+	if i := strings.Index(key, "->"); i >= 0 {
+		return key[i+2:]
+	}
+	return ""
 }
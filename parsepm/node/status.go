@@ -0,0 +1,44 @@
+package node
+
+import "strings"
+
+// FieldStatus holds field teams progress info, as typed in suivi workbook Statut, Acteur(s), N° Déplacement, Début and Fin columns
+type FieldStatus struct {
+	Status string // ripsite state (ripconst.State...)
+	Label  string // Statut cell value
+	Actors string
+	Trip   string // N° Déplacement
+	Begin  string
+	End    string
+}
+
+// IsEmpty returns true if no field info is defined
+func (fs FieldStatus) IsEmpty() bool {
+	return fs.Label == "" && fs.Actors == "" && fs.Trip == "" && fs.Begin == "" && fs.End == ""
+}
+
+// Readiness values
+const (
+	ReadinessDone     string = "Fait"
+	ReadinessCanceled string = "Sans objet"
+	ReadinessReady    string = "Prêt"
+	ReadinessBlocked  string = "Bloqué"
+)
+
+// Readiness tells whether a work can be started, given the field status of the works it depends on
+type Readiness struct {
+	State     string   // ReadinessDone, ReadinessCanceled, ReadinessReady or ReadinessBlocked ("" if not computed)
+	BlockedBy []string // works to be done first (ex: "Tirage TR-12", "Racco PT 3")
+}
+
+// IsFinished returns true if the receiver work is done or canceled (so that works depending on it are not blocked)
+func (r Readiness) IsFinished() bool {
+	return r.State == ReadinessDone || r.State == ReadinessCanceled
+}
+
+func (r Readiness) String() string {
+	if r.State == ReadinessBlocked && len(r.BlockedBy) > 0 {
+		return r.State + " : " + strings.Join(r.BlockedBy, ", ")
+	}
+	return r.State
+}
@@ -15,6 +15,8 @@ type Troncon struct {
 
 	NodeSource *Node
 	NodeDest   *Node
+
+	PullingStatus *FieldStatus // imported from suivi workbook (nil if not defined)
 }
 
 func NewTroncon(name string) *Troncon {
@@ -31,4 +33,28 @@ func (c Troncon) CapaString() string {
 	return fmt.Sprintf("%dFO", c.Capa)
 }
 
+const (
+	PullingUnderground string = "Souterrain"
+	PullingAerial      string = "Aérien"
+	PullingFacade      string = "Façade"
+)
+
+// PullingType returns the receiver main pulling type (the one having the longest length), or "" if no length is defined
+func (c Troncon) PullingType() string {
+	res, max := "", 0
+	for _, l := range []struct {
+		pulling string
+		length  int
+	}{
+		{PullingUnderground, c.UndergroundLength},
+		{PullingAerial, c.AerialLength},
+		{PullingFacade, c.FacadeLength},
+	} {
+		if l.length > max {
+			res, max = l.pulling, l.length
+		}
+	}
+	return res
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
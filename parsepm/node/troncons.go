@@ -24,3 +24,27 @@ func (ts Troncons) Get(name string) *Troncon {
 	}
 	return tr
 }
+
+// Merge adds given node troncons (as populated by ParseBPEXLS with a node dedicated Troncons) to the receiver, and rewires node TronconIn and TronconsOut to the receiver ones.
+//
+// Capa, NodeSource and NodeDest defined by node troncons override the receiver ones
+func (ts Troncons) Merge(n *Node, nodeTroncons Troncons) {
+	for name, nt := range nodeTroncons {
+		tr := ts.Get(name)
+		if nt.Capa != 0 {
+			tr.Capa = nt.Capa
+		}
+		if nt.NodeSource != nil {
+			tr.NodeSource = nt.NodeSource
+		}
+		if nt.NodeDest != nil {
+			tr.NodeDest = nt.NodeDest
+		}
+	}
+	if n.TronconIn != nil {
+		n.TronconIn = ts.Get(n.TronconIn.Name)
+	}
+	for name, tr := range n.TronconsOut {
+		n.TronconsOut[name] = ts.Get(tr.Name)
+	}
+}
@@ -26,3 +26,21 @@ func addHeaderRow(xs *xlsx.Sheet, cols []col) {
 		xs.Col(i).Width = ci.width
 	}
 }
+
+// writeFiberStock adds fiber stock columns (Nb Réserve, Nb Love, Nb Stockée) cells to given row
+func writeFiberStock(r *xlsx.Row, fs FiberStock) {
+	for _, value := range []int{fs.Reserved, fs.Loved, fs.Stored} {
+		r.AddCell().SetInt(value)
+	}
+}
+
+// writeFieldStatus adds field columns (Statut, Acteur(s), N° Déplacement, Début, Fin) cells to given row, empty if fs is nil
+func writeFieldStatus(r *xlsx.Row, fs *FieldStatus) {
+	if fs == nil {
+		writeSitePrefix(r, 5)
+		return
+	}
+	for _, value := range []string{fs.Label, fs.Actors, fs.Trip, fs.Begin, fs.End} {
+		r.AddCell().SetString(value)
+	}
+}
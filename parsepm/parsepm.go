@@ -1,76 +1,491 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
 	"log"
 	"os"
 	"path/filepath"
-)
+	"strings"
 
-const (
-	// Sogetrel Fibre ==================================================================================================
-	testClient  string = "Sogetrel Fibre"
-	testManager string = "WOIRGARD Pierre"
-
-	testXLS                string = `SRO 52-001-128`
-	testDir                string = `C:\Users\Laurent\OneDrive\Documents\EWIN Partages\Sogetrel\Chantier Fibre Aube\2022-03-24 SRO\`
-	testBPEDir             string = `4.PLANS DE SOUDURE`
-	testROPXlsx            string = `20210818-SRO-52-01-128-ROP-EXCEL.xlsx`
-	testCable94Xlsx        string = ``
-	testCableOptiqueC2Xlsx string = ``
-	testBoiteOptiqueD2Xlsx string = ``
-	//testCableOptiqueC2Xlsx string = `10_050_279_CABLE_OPTIQUE_D2.xlsx`
-	//testBoiteOptiqueD2Xlsx string = `10_050_279_BOITE_OPTIQUE_D2.xlsx`
-
-	// Axians Moselle ==================================================================================================
-	//testClient  string = "Axians Moselle"
-	//testManager string = "David MAUSSAND"
-	////testManager string = "Matthieu BECK"
-	//
-	//testXLS                string = `SARLB_PM04`
-	//testDir                string = `C:\Users\Laurent\OneDrive\Documents\TEMPORAIRE\Moselle\2020-11-25 SAR_PRO\CCAL_SAR_PM04\`
-	//testBPEDir             string = `CCAL_SAR_PM04_BPE`
-	//testROPXlsx            string = `CCAL_SAR_PM04_ROP\CCAL_SAR_PM04_ROP.xlsx`
-	//testCable94Xlsx        string = `9.4.xlsx` // to activate Pulling Activity
-	//testCableOptiqueC2Xlsx string = ``
-	//testBoiteOptiqueD2Xlsx string = ``
-
-	// Common ==========================================================================================================
-	testSiteId int = 86
+	"github.com/lpuig/ewin/chantiersalsace/dirbrowser"
+	"github.com/lpuig/ewin/chantiersalsace/parsemesure/measurement"
+	"github.com/lpuig/ewin/chantiersalsace/parsemesure/orangemacro"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/config"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/store"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
 )
 
-var EnableDestBPECable = map[string]string{
-	//"ELINE": "CABLE_%dFO_IMMEUBLE_M6_G657A2",
+// Usage : parsepm -project <worksite.json|worksite.yaml> [-diff <previous worksite.json|worksite.yaml|stored version>] [-store <store.db> [-version <label>] [-cached]] [-flag value ...]
+//
+// or : parsepm -rollup <project.json|project.yaml> [-flag value ...] to process several PM worksites and write a roll-up summary workbook
+//
+// any worksite project file field can be overridden with related flag (see parsepm -h)
+func main() {
+	projectFile := flag.String("project", "", "worksite project file (JSON or YAML)")
+	rollupFile := flag.String("rollup", "", "multi-PM project file (JSON or YAML) listing worksite project files, to process them all and write a roll-up summary")
+	opts := options{}
+	flag.StringVar(&opts.diffFile, "diff", "", "previous design version worksite project file (or stored version label), to report changes against")
+	flag.StringVar(&opts.version, "version", "", "design version label used to save (or load with -cached) zone in store (default to current date and time when saving, latest when loading)")
+	flag.BoolVar(&opts.cached, "cached", false, "load zone from store instead of parsing worksite files")
+	flag.StringVar(&opts.otdrDir, "otdr", "", "directory of OTDR campaign files (Orange macro xlsx) to reconcile with measurement targets")
+	flag.StringVar(&opts.trace, "trace", "", "print optical path(s) from given drawer position (drawer/line/column) or to given PT")
+	flag.BoolVar(&opts.stats, "stats", false, "print zone nodes per location type, troncons per capacity and works totals")
+	overrides := worksiteFlags()
+	flag.Parse()
+
+	if *rollupFile != "" {
+		err := rollup(*rollupFile, overrides, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ws := config.NewWorksite()
+	if *projectFile != "" {
+		var err error
+		ws, err = config.LoadWorksite(*projectFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	applyOverrides(ws, overrides)
+	if err := ws.Check(); err != nil {
+		flag.Usage()
+		log.Fatalf("invalid worksite definition: %s", err.Error())
+	}
+
+	_, err := run(ws, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
-func main() {
-	pm := zone.New()
-	// configure activity
-	pm.DoPulling = false
+// applyOverrides sets given worksite fields from command line flags
+func applyOverrides(ws *config.Worksite, overrides map[string]func(ws *config.Worksite)) {
+	flag.Visit(func(f *flag.Flag) {
+		if override, found := overrides[f.Name]; found {
+			override(ws)
+		}
+	})
+}
+
+// rollup processes all worksites of given multi-PM project file (each zone gets its own output files), and writes project roll-up summary
+// as <project name>_synthese.xlsx
+func rollup(file string, overrides map[string]func(ws *config.Worksite), opts options) error {
+	p, err := config.LoadProject(file)
+	if err != nil {
+		return err
+	}
+	wss, err := p.LoadWorksites()
+	if err != nil {
+		return fmt.Errorf("invalid project definition: %s", err.Error())
+	}
+	project := zone.NewProject(p.Name)
+	for _, ws := range wss {
+		applyOverrides(ws, overrides)
+		if err := ws.Check(); err != nil {
+			return fmt.Errorf("invalid worksite '%s' definition: %s", ws.Name, err.Error())
+		}
+		log.Printf("Process worksite '%s'\n", ws.Name)
+		pm, err := run(ws, opts)
+		if err != nil {
+			return fmt.Errorf("worksite '%s': %s", ws.Name, err.Error())
+		}
+		err = project.Add(ws.Name, pm)
+		if err != nil {
+			return err
+		}
+	}
+	project.UpdateReadiness()
+	return project.WriteSummaryXLS(p.Dir)
+}
+
+// worksiteFlags declares Worksite overriding flags, and returns override functions per flag name
+func worksiteFlags() map[string]func(ws *config.Worksite) {
+	res := map[string]func(ws *config.Worksite){}
+	stringFlag := func(name, usage string, field func(ws *config.Worksite) *string) {
+		val := flag.String(name, "", usage)
+		res[name] = func(ws *config.Worksite) { *field(ws) = *val }
+	}
+	boolFlag := func(name, usage string, field func(ws *config.Worksite) *bool) {
+		val := flag.Bool(name, false, usage)
+		res[name] = func(ws *config.Worksite) { *field(ws) = *val }
+	}
+
+	stringFlag("client", "client name", func(ws *config.Worksite) *string { return &ws.Client })
+	stringFlag("manager", "manager name", func(ws *config.Worksite) *string { return &ws.Manager })
+	stringFlag("name", "output files prefix", func(ws *config.Worksite) *string { return &ws.Name })
+	stringFlag("dir", "worksite base directory", func(ws *config.Worksite) *string { return &ws.Dir })
+	stringFlag("bpedir", "BPE directory", func(ws *config.Worksite) *string { return &ws.BPEDir })
+	stringFlag("blob", "BPE file pattern (easyfibre, sogetrel or glob pattern)", func(ws *config.Worksite) *string { return &ws.BlobPattern })
+	stringFlag("rop", "ROP file", func(ws *config.Worksite) *string { return &ws.ROPFile })
+	stringFlag("roplayout", "ROP layout (standard, axians, sogetrel or JSON layout file)", func(ws *config.Worksite) *string { return &ws.ROPLayout })
+	stringFlag("c94", "Quantité Cable 9.4 file", func(ws *config.Worksite) *string { return &ws.Cable94File })
+	stringFlag("c2", "Quantité Cable Optique C2 file", func(ws *config.Worksite) *string { return &ws.CableOptiqueC2File })
+	stringFlag("d2", "Quantité Boite Optique D2 file", func(ws *config.Worksite) *string { return &ws.BoiteOptiqueD2File })
+	stringFlag("coords", "nodes coordinates CSV file (PT name, latitude, longitude)", func(ws *config.Worksite) *string { return &ws.CoordinatesFile })
+	stringFlag("suivi", "suivi workbook to import field status from", func(ws *config.Worksite) *string { return &ws.SuiviFile })
+	stringFlag("store", "zone store database file (parsed zones are saved in it per version)", func(ws *config.Worksite) *string { return &ws.StoreFile })
+	stringFlag("materials", "material reference table JSON file (box model and cable type articles)", func(ws *config.Worksite) *string { return &ws.MaterialsFile })
+	stringFlag("bpu", "BPU workbook (planning throughputs are derived from its Work values)", func(ws *config.Worksite) *string { return &ws.BPUFile })
+	stringFlag("operations", "operation vocabulary JSON file (operation label synonyms per source : bpe, rop, zacable)", func(ws *config.Worksite) *string { return &ws.OperationsFile })
+	stringFlag("drums", "cable drums inventory workbook (drum, cable type, capacity and remaining length columns)", func(ws *config.Worksite) *string { return &ws.DrumsFile })
+
+	boolFlag("plan", "plan field interventions (pre-fills suivi N° Déplacement columns and adds a Planning sheet)", func(ws *config.Worksite) *bool { return &ws.PlanTrips })
+	boolFlag("merge", "regenerate suivi workbook keeping its field columns", func(ws *config.Worksite) *bool { return &ws.MergeSuivi })
+	boolFlag("strict", "stop ROP file parsing on first fatal error", func(ws *config.Worksite) *bool { return &ws.StrictRop })
+	boolFlag("pulling", "enable pulling activity", func(ws *config.Worksite) *bool { return &ws.Activities.Pulling })
+	boolFlag("junctions", "enable junctions activity", func(ws *config.Worksite) *bool { return &ws.Activities.Junctions })
+	boolFlag("eline", "enable ELINE junctions", func(ws *config.Worksite) *bool { return &ws.Activities.Eline })
+	boolFlag("othereline", "enable junctions other than ELINE", func(ws *config.Worksite) *bool { return &ws.Activities.OtherThanEline })
+	boolFlag("measurement", "enable measurement activity", func(ws *config.Worksite) *bool { return &ws.Activities.Measurement })
+
+	bpeLayouts := flag.String("bpelayouts", "", "comma separated BPE layouts (built-in names or JSON layout files)")
+	res["bpelayouts"] = func(ws *config.Worksite) { ws.BPELayouts = strings.Split(*bpeLayouts, ",") }
+
+	bpeInclude := flag.String("bpeinclude", "", "comma separated BPE file glob patterns (patterns with a '/' match path relative to BPE directory, others match file name)")
+	res["bpeinclude"] = func(ws *config.Worksite) { ws.BPEInclude = strings.Split(*bpeInclude, ",") }
+	bpeExclude := flag.String("bpeexclude", "", "comma separated glob patterns of files to be ignored in BPE directory")
+	res["bpeexclude"] = func(ws *config.Worksite) { ws.BPEExclude = strings.Split(*bpeExclude, ",") }
+
+	boolFlag("bperecursive", "search BPE file patterns without '/' in BPE directory sub dirs too", func(ws *config.Worksite) *bool { return &ws.BPERecursive })
+
+	bpeWorkers := flag.Int("bpeworkers", 0, "max number of BPE files parsed concurrently (number of CPUs if 0)")
+	res["bpeworkers"] = func(ws *config.Worksite) { ws.BPEWorkers = *bpeWorkers }
+
+	siteId := flag.Int("siteid", 0, "ripsite Id (JSON file name)")
+	res["siteid"] = func(ws *config.Worksite) { ws.SiteId = *siteId }
+
+	return res
+}
 
-	pm.DoJunctions = true
-	pm.DoEline = true
-	pm.DoOtherThanEline = true
+// options holds command line options which are not related to worksite definition
+type options struct {
+	diffFile string // previous design version project file, or stored version label
+	version  string // design version label in store
+	cached   bool   // load zone from store instead of parsing
+	trace    string // drawer position or PT name to trace optical paths from / to
+	stats    bool   // print zone statistics
+	otdrDir  string // OTDR campaigns directory
+}
 
-	pm.DoMeasurement = true
+// run parses (or loads from store) given worksite zone, writes its output files and returns it
+func run(ws *config.Worksite, opts options) (*zone.Zone, error) {
+	pm, err := storedZone(ws, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.diffFile != "" {
+		err = writeDiff(pm, ws, opts.diffFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not report design changes: %s", err.Error())
+		}
+	}
 
-	// Uncomment zone BlobPattern setup for Sogetrel
-	pm.BlobPattern = zone.Blobpattern_Sogetrel // for Sogetrel Worksite
+	if opts.trace != "" {
+		printPaths(pm, opts.trace)
+	}
+
+	if opts.stats {
+		printStats(pm)
+	}
+
+	if opts.otdrDir != "" {
+		err = writeReconcile(pm, ws, opts.otdrDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not reconcile OTDR campaigns: %s", err.Error())
+		}
+	}
+
+	if ws.DrumsFile != "" {
+		err = writeDrums(pm, ws)
+		if err != nil {
+			return nil, fmt.Errorf("could not allocate cable drums: %s", err.Error())
+		}
+	}
+
+	if ws.MaterialsFile != "" {
+		err = writeBOM(pm, ws)
+		if err != nil {
+			return nil, fmt.Errorf("could not write bill of materials: %s", err.Error())
+		}
+	}
+
+	if ws.SuiviFile != "" {
+		diags, err := pm.ParseSuiviXLS(ws.Path(ws.SuiviFile))
+		if err != nil {
+			return nil, fmt.Errorf("could not import suivi file: %s", err.Error())
+		}
+		for _, diag := range diags {
+			fmt.Printf("\t%s\n", diag.String())
+		}
+	}
+
+	if ws.PlanTrips {
+		err = planInterventions(pm)
+		if err != nil {
+			return nil, fmt.Errorf("could not plan interventions: %s", err.Error())
+		}
+	}
+
+	if ws.MergeSuivi {
+		diags, err := pm.MergeXLS(ws.Dir, ws.Name)
+		if err != nil {
+			log.Printf("could not merge XLSx : %s", err)
+		}
+		for _, diag := range diags {
+			fmt.Printf("\t%s\n", diag.String())
+		}
+	} else {
+		err = pm.WriteXLS(ws.Dir, ws.Name)
+		if err != nil {
+			log.Printf("could not write XLSx : %s", err)
+		}
+	}
+
+	err = pm.WriteDOT(ws.Dir, ws.Name)
+	if err != nil {
+		log.Printf("could not write DOT file : %s", err)
+	}
+	err = pm.WriteSVG(ws.Dir, ws.Name)
+	if err != nil {
+		log.Printf("could not write SVG file : %s", err)
+	}
+
+	if nb := pm.LocatedNodes(); nb > 0 {
+		log.Printf("Write GeoJSON and KML files (%d located nodes)\n", nb)
+		err = pm.WriteGeoJSON(ws.Dir, ws.Name)
+		if err != nil {
+			log.Printf("could not write GeoJSON file : %s", err)
+		}
+		err = pm.WriteKML(ws.Dir, ws.Name)
+		if err != nil {
+			log.Printf("could not write KML file : %s", err)
+		}
+	}
+
+	err = pm.WriteControlsJSON(ws.Dir, ws.Name)
+	if err != nil {
+		log.Printf("could not write controls JSON file : %s", err)
+	}
+
+	err = pm.WriteJSON(ws.Dir, ws.Name, ws.Client, ws.Manager, ws.SiteId)
+	if err != nil {
+		return nil, fmt.Errorf("could not write JSON file : %s", err.Error())
+	}
+	return pm, nil
+}
+
+// writeDiff parses the previous design version described by given project file, and writes changes brought by pm as <name>_diff.xlsx
+func writeDiff(pm *zone.Zone, ws *config.Worksite, prevFile string) error {
+	prev, err := previousZone(ws, prevFile)
+	if err != nil {
+		return err
+	}
+	changes := zone.Diff(prev, pm)
+	for _, change := range changes {
+		fmt.Printf("\t%s\n", change.String())
+	}
+	diffFile := filepath.Join(ws.Dir, ws.Name+"_diff.xlsx")
+	log.Printf("Write %d design change(s) to '%s'\n", len(changes), diffFile)
+	return changes.WriteXLS(diffFile)
+}
+
+// previousZone returns the previous design version zone, parsed from given project file, or loaded from worksite store if prev is not an existing file
+func previousZone(ws *config.Worksite, prev string) (*zone.Zone, error) {
+	if ws.StoreFile != "" && !exists(prev) {
+		log.Printf("Load previous design version '%s' from store\n", prev)
+		return loadZone(ws, prev)
+	}
+	prevWs, err := config.LoadWorksite(prev)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Parse previous design version '%s'\n", prev)
+	return parseZone(prevWs)
+}
+
+// storedZone returns worksite zone, loaded from worksite store if cached option is set, or parsed from worksite files (and then saved in worksite store if any)
+func storedZone(ws *config.Worksite, opts options) (*zone.Zone, error) {
+	if opts.cached {
+		if ws.StoreFile == "" {
+			return nil, fmt.Errorf("cached option requires a store file")
+		}
+		return loadZone(ws, opts.version)
+	}
+	pm, err := parseZone(ws)
+	if err != nil {
+		return nil, err
+	}
+	if ws.StoreFile == "" {
+		return pm, nil
+	}
+	st, err := store.Open(ws.Path(ws.StoreFile))
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	zr, err := st.Save(ws.Name, opts.version, pm)
+	if err != nil {
+		return nil, fmt.Errorf("could not save zone in store: %s", err.Error())
+	}
+	log.Printf("Zone saved in store as version '%s'\n", zr.Version)
+	return pm, nil
+}
+
+// loadZone returns given version of worksite zone from worksite store (latest one if version is empty)
+func loadZone(ws *config.Worksite, version string) (*zone.Zone, error) {
+	st, err := store.Open(ws.Path(ws.StoreFile))
+	if err != nil {
+		return nil, err
+	}
+	defer st.Close()
+	pm, err := ws.NewZone()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := st.LoadZone(ws.Name, version, pm)
+	if err != nil {
+		return nil, fmt.Errorf("could not load zone from store: %s", err.Error())
+	}
+	log.Printf("Zone loaded from store (version '%s' saved at %s)\n", zr.Version, zr.SavedAt)
+	return pm, nil
+}
+
+// writeReconcile parses all OTDR campaign files found in given directory, and writes their reconciliation with pm measurement targets
+func writeReconcile(pm *zone.Zone, ws *config.Worksite, otdrDir string) error {
+	log.Printf("Parse OTDR campaigns directory\n")
+	campaigns := []measurement.Campaign{}
+	err := dirbrowser.Process(ws.Path(otdrDir), ".xlsx", func(file string) error {
+		campaign, err := orangemacro.Parse(file)
+		if err != nil {
+			fmt.Printf("\tSkipping '%s': %s\n", filepath.Base(file), err.Error())
+			return nil
+		}
+		campaigns = append(campaigns, campaign)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	diags, err := pm.WriteReconcileXLS(ws.Dir, ws.Name, campaigns)
+	for _, diag := range diags {
+		fmt.Printf("\t%s\n", diag.String())
+	}
+	return err
+}
+
+// writeDrums parses worksite cable drums inventory, and writes pm cables allocation to drums
+func writeDrums(pm *zone.Zone, ws *config.Worksite) error {
+	if len(pm.Cables) == 0 {
+		return fmt.Errorf("no cable found in zone")
+	}
+	log.Printf("Parse cable drums inventory\n")
+	drums, err := pm.ParseDrumsXLS(ws.Path(ws.DrumsFile))
+	if err != nil {
+		return err
+	}
+	unallocated, err := pm.WriteDrumsXLS(ws.Dir, ws.Name, drums)
+	if err != nil {
+		return err
+	}
+	log.Printf("%d cable(s) allocated to %d drum(s), %d unallocated\n", len(pm.Cables)-len(unallocated), len(drums), len(unallocated))
+	return nil
+}
+
+// writeBOM writes pm bill of materials, and lists box models and cable types missing in worksite material table
+func writeBOM(pm *zone.Zone, ws *config.Worksite) error {
+	log.Printf("Write bill of materials\n")
+	ms, err := pm.WriteBOM(ws.Dir, ws.Name)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms.Unreferenced() {
+		fmt.Printf("\tnot found in material table: %s\n", m.String())
+	}
+	return nil
+}
+
+// planInterventions plans pm field interventions, and prints the resulting number of days per activity
+func planInterventions(pm *zone.Zone) error {
+	log.Printf("Plan field interventions\n")
+	interventions, err := pm.Plan()
+	if err != nil {
+		return err
+	}
+	lastDay := map[string]int{}
+	for _, itv := range interventions {
+		if end := itv.Day + itv.Days - 1; end > lastDay[itv.Activity] {
+			lastDay[itv.Activity] = end
+		}
+	}
+	for _, activity := range []string{"Tirage", "Racco", "Mesures"} {
+		if day, found := lastDay[activity]; found {
+			fmt.Printf("\t%s : done by day %d\n", activity, day)
+		}
+	}
+	log.Printf("%d intervention(s) planned\n", len(interventions))
+	return nil
+}
+
+// printPaths prints the optical path starting at given drawer position, or all optical paths ending on given PT
+func printPaths(pm *zone.Zone, trace string) {
+	if path, err := pm.TracePath(trace); err == nil {
+		fmt.Printf("%s\n", path.String())
+		return
+	}
+	paths := pm.PathsTo(trace)
+	if len(paths) == 0 {
+		log.Printf("no optical path found from or to '%s'", trace)
+		return
+	}
+	for _, path := range paths {
+		fmt.Printf("%s\n", path.String())
+	}
+}
+
+// printStats prints given zone nodes per location type, troncons per capacity and works totals, using its store query API
+func printStats(pm *zone.Zone) {
+	zr := store.NewZoneRecord(pm)
+	for _, locationType := range []string{"PM", "BPE", "PBO"} {
+		fmt.Printf("%s : %d node(s)\n", locationType, len(zr.NodesByType(locationType)))
+	}
+	for _, capa := range zr.TronconCapas() {
+		fmt.Printf("Troncons %d FO : %d\n", capa, len(zr.TronconsByCapa(capa)))
+	}
+	pm.UpdateReadiness()
+	t := pm.Totals()
+	fmt.Printf("Tirage : %d cable(s), %d m, %d done\n", t.Cables, t.CableLength, t.CablesDone)
+	fmt.Printf("Racco : %d junction(s), %d splice(s), %d done, %d ready\n", t.Junctions, t.Splices, t.JunctionsDone, t.JunctionsReady)
+	fmt.Printf("Mesures : %d PT, %d fiber(s), %d done, %d ready\n", t.Measurements, t.Fibers, t.MeasurementsDone, t.MeasurementsReady)
+}
+
+// parseZone creates worksite zone from its design files (BPE directory, ROP and quantity files)
+func parseZone(ws *config.Worksite) (*zone.Zone, error) {
+	pm, err := ws.NewZone()
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("Parse BPE directory\n")
-	err := pm.ParseBPEDir(filepath.Join(testDir, testBPEDir))
+	err = pm.ParseBPEDir(ws.Path(ws.BPEDir))
 	if err != nil {
-		log.Fatal("could not parse BPE Directory:", err)
+		return nil, fmt.Errorf("could not parse BPE Directory: %s", err.Error())
 	}
 
 	log.Printf("Parse ROP file\n")
-	ropFile := filepath.Join(testDir, testROPXlsx)
-	if exists(ropFile) {
+	ropFile := ws.Path(ws.ROPFile)
+	if ropFile != "" && exists(ropFile) {
 		// If ROP File exist, parse it to create BPE Tree
-		err = pm.ParseROPXLS(ropFile) // rp.zone.Nodes["PT 204558"]
+		diags, err := pm.ParseROPXLS(ropFile)
+		for _, diag := range diags {
+			fmt.Printf("\t%s\n", diag.String())
+		}
 		if err != nil {
-			log.Fatal("could not parse ROP file:", err)
+			return nil, fmt.Errorf("could not parse ROP file: %s", err.Error())
 		}
 
 		fmt.Print(pm.Sro.Tree("- ", "", 0))
@@ -81,55 +496,53 @@ func main() {
 		pm.CreateBPETree()
 	}
 
-	if testCable94Xlsx != "" {
-		cable94File := filepath.Join(testDir, testCable94Xlsx)
+	if ws.Cable94File != "" {
+		cable94File := ws.Path(ws.Cable94File)
 		if !exists(cable94File) {
-			log.Fatal("cable file '%s' does not exist\n", cable94File)
+			return nil, fmt.Errorf("cable file '%s' does not exist", cable94File)
 		}
 		err = pm.ParseQuantiteCableXLS(cable94File)
 		if err != nil {
-			log.Fatal("could not parse Quantité Cable 9.4 file:", err)
+			return nil, fmt.Errorf("could not parse Quantité Cable 9.4 file: %s", err.Error())
 		}
 	}
 
-	if testCableOptiqueC2Xlsx != "" {
-		cableC2File := filepath.Join(testDir, testCableOptiqueC2Xlsx)
+	if ws.CableOptiqueC2File != "" {
+		cableC2File := ws.Path(ws.CableOptiqueC2File)
 		if !exists(cableC2File) {
-			log.Fatal("cable file '%s' does not exist\n", cableC2File)
+			return nil, fmt.Errorf("cable file '%s' does not exist", cableC2File)
 		}
 		err = pm.ParseQuantiteCableOptiqueC2Xlsx(cableC2File)
 		if err != nil {
-			log.Fatal("could not parse Quantité Cable Optique D2 file:", err)
+			return nil, fmt.Errorf("could not parse Quantité Cable Optique C2 file: %s", err.Error())
 		}
 	}
 
-	if testBoiteOptiqueD2Xlsx != "" {
-		boFile := filepath.Join(testDir, testBoiteOptiqueD2Xlsx)
+	if ws.BoiteOptiqueD2File != "" {
+		boFile := ws.Path(ws.BoiteOptiqueD2File)
 		if !exists(boFile) {
-			log.Fatal("Boite Optique file '%s' does not exist\n", boFile)
+			return nil, fmt.Errorf("Boite Optique file '%s' does not exist", boFile)
 		}
 		err = pm.ParseQuantiteBoiteOptiqueD2Xlsx(boFile)
 		if err != nil {
-			log.Fatal("could not parse Quantité Boite Optique D2 file:", err)
+			return nil, fmt.Errorf("could not parse Quantité Boite Optique D2 file: %s", err.Error())
 		}
 	}
 
-	pm.CheckConsistency()
-
-	// Force CableType on selected Troncons (used for Immeuble Pulling activity)
-	if len(EnableDestBPECable) > 0 {
-		pm.EnableCables(EnableDestBPECable)
+	if ws.CoordinatesFile != "" {
+		err = pm.ParseCoordinatesCSV(ws.Path(ws.CoordinatesFile))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse coordinates file: %s", err.Error())
+		}
 	}
 
-	err = pm.WriteXLS(testDir, testXLS)
-	if err != nil {
-		log.Printf("could not write XLSx : %s", err)
-	}
+	pm.CheckConsistency()
 
-	err = pm.WriteJSON(testDir, testXLS, testClient, testManager, testSiteId)
-	if err != nil {
-		log.Fatal("could not write JSON file :", err)
+	// Force CableType on selected Troncons (used for Immeuble Pulling activity)
+	if len(ws.EnableDestBPECable) > 0 {
+		pm.EnableCables(ws.EnableDestBPECable)
 	}
+	return pm, nil
 }
 
 func exists(file string) bool {
@@ -0,0 +1,411 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
+)
+
+// NodeRecord is the persisted form of a node.Node. Troncons and children are referenced by their index in ZoneRecord lists (-1 if none)
+type NodeRecord struct {
+	Name         string
+	PtName       string
+	BPEType      string
+	LocationType string
+	Address      string
+	DistFromPM   int
+	Lat, Long    float64
+
+	TronconIn   int
+	TronconsOut map[string]int
+	Operation   map[string]int
+	Fibers      node.Operations
+	Stock       node.FiberStock
+
+	UnknownOperations map[string]int
+
+	StartDrawer string
+	EndDrawer   string
+	SplicePT    []string
+
+	Children []int
+	IsChild  bool
+
+	JunctionStatus    *node.FieldStatus
+	MeasurementStatus *node.FieldStatus
+}
+
+// TronconRecord is the persisted form of a node.Troncon. Source and destination nodes are referenced by their index in ZoneRecord Nodes list (-1 if none)
+type TronconRecord struct {
+	Name              string
+	Capa              int
+	CableType         string
+	LoveLength        int
+	UndergroundLength int
+	AerialLength      int
+	FacadeLength      int
+
+	NodeSource int
+	NodeDest   int
+
+	PullingStatus *node.FieldStatus
+}
+
+// CableRecord is the persisted form of a node.Cable
+type CableRecord struct {
+	Capa     int
+	Length   int
+	Troncons []int
+
+	Status *node.FieldStatus
+}
+
+// MeasurementRecord is a zone measurement target (as listed in Mesures sheet)
+type MeasurementRecord struct {
+	PtName      string
+	NbFiber     int
+	Dist        int
+	NodeNames   []string
+	MaxLoss1310 float64
+	MaxLoss1550 float64
+}
+
+// PathStepRecord is the persisted form of a zone.PathStep ROP route step. Node and troncon are referenced by their index in ZoneRecord lists (-1 if none)
+type PathStepRecord struct {
+	Node    int
+	Troncon int
+	Tube    string
+	Ope     string
+}
+
+// PathRecord is the persisted form of a zone.OpticalPath (its fiber level steps are derived again from nodes splice plan operations on restore)
+type PathRecord struct {
+	Drawer  string
+	Service string
+	Fiber   string
+	Route   []PathStepRecord
+}
+
+// ZoneRecord is the persisted form of a parsed zone.Zone
+type ZoneRecord struct {
+	Worksite string
+	Version  string
+	SavedAt  string
+
+	Nodes    []*NodeRecord    // all zone nodes (including PM nodes created while building zone tree)
+	Troncons []*TronconRecord // all zone troncons (including PM nodes ones)
+	Cables   []*CableRecord
+
+	Sro          int
+	NodeRoots    []int
+	ZoneNodes    []int // Zone.Nodes
+	ZoneTroncons []int // Zone.Troncons
+
+	Measurements []*MeasurementRecord
+	Paths        []*PathRecord
+	Diagnostics  zone.Diagnostics // inconsistencies found while parsing zone files
+}
+
+// NewZoneRecord returns the persisted form of given zone
+func NewZoneRecord(z *zone.Zone) *ZoneRecord {
+	zr := &ZoneRecord{Diagnostics: z.Diagnostics}
+	nodeIds := map[*node.Node]int{}
+	tronconIds := map[*node.Troncon]int{}
+
+	var addNode func(n *node.Node) int
+	addNode = func(n *node.Node) int {
+		if n == nil {
+			return -1
+		}
+		if id, found := nodeIds[n]; found {
+			return id
+		}
+		nodeIds[n] = len(zr.Nodes)
+		zr.Nodes = append(zr.Nodes, nil)
+		for _, cn := range n.Children {
+			addNode(cn)
+		}
+		return nodeIds[n]
+	}
+	addTroncon := func(tr *node.Troncon) int {
+		if tr == nil {
+			return -1
+		}
+		if id, found := tronconIds[tr]; found {
+			return id
+		}
+		tronconIds[tr] = len(zr.Troncons)
+		zr.Troncons = append(zr.Troncons, nil)
+		return tronconIds[tr]
+	}
+
+	// assign node and troncon ids
+	zr.Sro = addNode(z.Sro)
+	for _, root := range z.NodeRoots {
+		zr.NodeRoots = append(zr.NodeRoots, addNode(root))
+	}
+	for _, ptName := range sortedNodeNames(z.Nodes) {
+		zr.ZoneNodes = append(zr.ZoneNodes, addNode(z.Nodes[ptName]))
+	}
+	for _, name := range sortedTronconNames(z.Troncons) {
+		zr.ZoneTroncons = append(zr.ZoneTroncons, addTroncon(z.Troncons[name]))
+	}
+	nodes := make([]*node.Node, len(zr.Nodes))
+	for n, id := range nodeIds {
+		nodes[id] = n
+	}
+	for _, n := range nodes {
+		addTroncon(n.TronconIn)
+		for _, name := range sortedTronconNames(n.TronconsOut) {
+			addTroncon(n.TronconsOut[name])
+		}
+	}
+	for _, c := range z.Cables {
+		for _, tr := range c.Troncons {
+			addTroncon(tr)
+		}
+	}
+
+	// populate records
+	for id, n := range nodes {
+		nr := &NodeRecord{
+			Name:              n.Name,
+			PtName:            n.PtName,
+			BPEType:           n.BPEType,
+			LocationType:      n.LocationType,
+			Address:           n.Address,
+			DistFromPM:        n.DistFromPM,
+			Lat:               n.Lat,
+			Long:              n.Long,
+			TronconIn:         addTroncon(n.TronconIn),
+			TronconsOut:       map[string]int{},
+			Operation:         n.Operation,
+			Fibers:            n.Fibers,
+			Stock:             n.Stock,
+			UnknownOperations: n.UnknownOperations,
+			StartDrawer:       n.StartDrawer,
+			EndDrawer:         n.EndDrawer,
+			SplicePT:          n.SplicePT,
+			IsChild:           n.IsChild,
+			JunctionStatus:    n.JunctionStatus,
+			MeasurementStatus: n.MeasurementStatus,
+		}
+		for name, tr := range n.TronconsOut {
+			nr.TronconsOut[name] = addTroncon(tr)
+		}
+		for _, cn := range n.Children {
+			nr.Children = append(nr.Children, addNode(cn))
+		}
+		zr.Nodes[id] = nr
+
+		if wf := n.GetToBeMeasuredFiber(); wf > 0 {
+			mr := &MeasurementRecord{PtName: n.PtName, NbFiber: wf, Dist: n.DistFromPM, NodeNames: n.SplicePT}
+			mr.MaxLoss1310, mr.MaxLoss1550 = z.LossBudget.NodeMaxLoss(n)
+			zr.Measurements = append(zr.Measurements, mr)
+		}
+	}
+	for tr, id := range tronconIds {
+		zr.Troncons[id] = &TronconRecord{
+			Name:              tr.Name,
+			Capa:              tr.Capa,
+			CableType:         tr.CableType,
+			LoveLength:        tr.LoveLength,
+			UndergroundLength: tr.UndergroundLength,
+			AerialLength:      tr.AerialLength,
+			FacadeLength:      tr.FacadeLength,
+			NodeSource:        nodeId(nodeIds, tr.NodeSource),
+			NodeDest:          nodeId(nodeIds, tr.NodeDest),
+			PullingStatus:     tr.PullingStatus,
+		}
+	}
+	for _, c := range z.Cables {
+		cr := &CableRecord{Capa: c.Capa, Length: c.Length, Status: c.Status}
+		for _, tr := range c.Troncons {
+			cr.Troncons = append(cr.Troncons, tronconIds[tr])
+		}
+		zr.Cables = append(zr.Cables, cr)
+	}
+	for _, op := range z.Paths {
+		pr := &PathRecord{Drawer: op.Drawer, Service: op.Service, Fiber: op.Fiber}
+		for _, step := range op.Route {
+			pr.Route = append(pr.Route, PathStepRecord{
+				Node:    nodeId(nodeIds, step.Node),
+				Troncon: tronconId(tronconIds, step.Troncon),
+				Tube:    step.Tube,
+				Ope:     step.Ope,
+			})
+		}
+		zr.Paths = append(zr.Paths, pr)
+	}
+	return zr
+}
+
+func nodeId(nodeIds map[*node.Node]int, n *node.Node) int {
+	if id, found := nodeIds[n]; found {
+		return id
+	}
+	return -1
+}
+
+func tronconId(tronconIds map[*node.Troncon]int, tr *node.Troncon) int {
+	if id, found := tronconIds[tr]; found {
+		return id
+	}
+	return -1
+}
+
+func sortedNodeNames(nodes node.Nodes) []string {
+	res := make([]string, 0, len(nodes))
+	for name := range nodes {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func sortedTronconNames(troncons node.Troncons) []string {
+	res := make([]string, 0, len(troncons))
+	for name := range troncons {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Restore populates given zone (as returned by Worksite.NewZone) with receiver nodes, troncons and cables. Receiver parsing diagnostics are
+// prepended to zone ones
+func (zr *ZoneRecord) Restore(z *zone.Zone) {
+	nodes := make([]*node.Node, len(zr.Nodes))
+	for id, nr := range zr.Nodes {
+		n := node.NewNode()
+		n.Name = nr.Name
+		n.PtName = nr.PtName
+		n.BPEType = nr.BPEType
+		n.LocationType = nr.LocationType
+		n.Address = nr.Address
+		n.DistFromPM = nr.DistFromPM
+		n.Lat, n.Long = nr.Lat, nr.Long
+		if nr.Operation != nil {
+			n.Operation = nr.Operation
+		}
+		n.Fibers = nr.Fibers
+		n.Stock = nr.Stock
+		n.UnknownOperations = nr.UnknownOperations
+		n.StartDrawer = nr.StartDrawer
+		n.EndDrawer = nr.EndDrawer
+		n.SplicePT = nr.SplicePT
+		n.IsChild = nr.IsChild
+		n.JunctionStatus = nr.JunctionStatus
+		n.MeasurementStatus = nr.MeasurementStatus
+		nodes[id] = n
+	}
+	getNode := func(id int) *node.Node {
+		if id < 0 {
+			return nil
+		}
+		return nodes[id]
+	}
+
+	troncons := make([]*node.Troncon, len(zr.Troncons))
+	for id, tr := range zr.Troncons {
+		troncons[id] = &node.Troncon{
+			Name:              tr.Name,
+			Capa:              tr.Capa,
+			CableType:         tr.CableType,
+			LoveLength:        tr.LoveLength,
+			UndergroundLength: tr.UndergroundLength,
+			AerialLength:      tr.AerialLength,
+			FacadeLength:      tr.FacadeLength,
+			NodeSource:        getNode(tr.NodeSource),
+			NodeDest:          getNode(tr.NodeDest),
+			PullingStatus:     tr.PullingStatus,
+		}
+	}
+	getTroncon := func(id int) *node.Troncon {
+		if id < 0 {
+			return nil
+		}
+		return troncons[id]
+	}
+
+	for id, nr := range zr.Nodes {
+		n := nodes[id]
+		n.TronconIn = getTroncon(nr.TronconIn)
+		for name, trId := range nr.TronconsOut {
+			n.TronconsOut[name] = getTroncon(trId)
+		}
+		for _, cId := range nr.Children {
+			n.Children = append(n.Children, nodes[cId])
+		}
+	}
+
+	z.Sro = getNode(zr.Sro)
+	z.NodeRoots = []*node.Node{}
+	for _, id := range zr.NodeRoots {
+		z.NodeRoots = append(z.NodeRoots, nodes[id])
+	}
+	z.Nodes = node.NewNodes()
+	for _, id := range zr.ZoneNodes {
+		z.Nodes.Add(nodes[id])
+	}
+	z.Troncons = node.NewTroncons()
+	for _, id := range zr.ZoneTroncons {
+		z.Troncons.Add(troncons[id])
+	}
+	z.Cables = node.NewCables()
+	for _, cr := range zr.Cables {
+		c := &node.Cable{Capa: cr.Capa, Length: cr.Length, Status: cr.Status}
+		for _, id := range cr.Troncons {
+			c.Troncons = append(c.Troncons, troncons[id])
+		}
+		z.Cables.Add(c)
+	}
+	z.Paths = nil
+	for _, pr := range zr.Paths {
+		op := &zone.OpticalPath{Drawer: pr.Drawer, Service: pr.Service, Fiber: pr.Fiber}
+		for _, sr := range pr.Route {
+			op.Route = append(op.Route, zone.PathStep{Node: getNode(sr.Node), Troncon: getTroncon(sr.Troncon), Tube: sr.Tube, Ope: sr.Ope})
+		}
+		z.Paths = append(z.Paths, op)
+	}
+	z.ResolvePaths()
+	z.Diagnostics = append(zr.Diagnostics, z.Diagnostics...)
+}
+
+// NodesByType returns zone nodes having given location type (PM, BPE, PBO), sorted by PT name
+func (zr *ZoneRecord) NodesByType(locationType string) []*NodeRecord {
+	res := []*NodeRecord{}
+	for _, id := range zr.ZoneNodes {
+		if nr := zr.Nodes[id]; strings.EqualFold(nr.LocationType, locationType) {
+			res = append(res, nr)
+		}
+	}
+	return res
+}
+
+// TronconCapas returns the distinct capacities of zone troncons, sorted
+func (zr *ZoneRecord) TronconCapas() []int {
+	found := map[int]bool{}
+	res := []int{}
+	for _, id := range zr.ZoneTroncons {
+		if capa := zr.Troncons[id].Capa; !found[capa] {
+			found[capa] = true
+			res = append(res, capa)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// TronconsByCapa returns zone troncons having given capacity (number of fibers), sorted by name
+func (zr *ZoneRecord) TronconsByCapa(capa int) []*TronconRecord {
+	res := []*TronconRecord{}
+	for _, id := range zr.ZoneTroncons {
+		if tr := zr.Troncons[id]; tr.Capa == capa {
+			res = append(res, tr)
+		}
+	}
+	return res
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketZones  = []byte("zones")  // one sub-bucket per worksite, holding ZoneRecord JSON by version
+	bucketLatest = []byte("latest") // last saved version, by worksite
+)
+
+// Store persists parsed zones per worksite and version in an embedded (bbolt) database file
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (or creates) the store database file
+func Open(file string) (*Store, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open store '%s': %s", file, err.Error())
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save persists given zone as the given version of worksite (overwriting it if already existing), and returns the saved record.
+//
+// If version is empty, current date and time is used
+func (s *Store) Save(worksite, version string, z *zone.Zone) (*ZoneRecord, error) {
+	now := time.Now()
+	if version == "" {
+		version = now.Format("2006-01-02_150405")
+	}
+	zr := NewZoneRecord(z)
+	zr.Worksite = worksite
+	zr.Version = version
+	zr.SavedAt = now.Format(time.RFC3339)
+	content, err := json.Marshal(zr)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		zones, err := tx.CreateBucketIfNotExists(bucketZones)
+		if err != nil {
+			return err
+		}
+		versions, err := zones.CreateBucketIfNotExists([]byte(worksite))
+		if err != nil {
+			return err
+		}
+		err = versions.Put([]byte(version), content)
+		if err != nil {
+			return err
+		}
+		latest, err := tx.CreateBucketIfNotExists(bucketLatest)
+		if err != nil {
+			return err
+		}
+		return latest.Put([]byte(worksite), []byte(version))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// Load returns the given version of worksite zone record (last saved one if version is empty)
+func (s *Store) Load(worksite, version string) (*ZoneRecord, error) {
+	zr := &ZoneRecord{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if version == "" {
+			if latest := tx.Bucket(bucketLatest); latest != nil {
+				version = string(latest.Get([]byte(worksite)))
+			}
+		}
+		versions := worksiteBucket(tx, worksite)
+		if versions == nil {
+			return fmt.Errorf("unknown worksite '%s'", worksite)
+		}
+		content := versions.Get([]byte(version))
+		if content == nil {
+			return fmt.Errorf("unknown version '%s' for worksite '%s'", version, worksite)
+		}
+		return json.Unmarshal(content, zr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// LoadZone populates given zone (as returned by Worksite.NewZone) with the given version of worksite zone (last saved one if version is empty)
+func (s *Store) LoadZone(worksite, version string, z *zone.Zone) (*ZoneRecord, error) {
+	zr, err := s.Load(worksite, version)
+	if err != nil {
+		return nil, err
+	}
+	zr.Restore(z)
+	return zr, nil
+}
+
+func worksiteBucket(tx *bolt.Tx, worksite string) *bolt.Bucket {
+	zones := tx.Bucket(bucketZones)
+	if zones == nil {
+		return nil
+	}
+	return zones.Bucket([]byte(worksite))
+}
+
+// Worksites returns the stored worksite names, sorted
+func (s *Store) Worksites() ([]string, error) {
+	res := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		zones := tx.Bucket(bucketZones)
+		if zones == nil {
+			return nil
+		}
+		return zones.ForEach(func(k, v []byte) error {
+			res = append(res, string(k))
+			return nil
+		})
+	})
+	return res, err
+}
+
+// Versions returns the stored versions of given worksite, sorted
+func (s *Store) Versions(worksite string) ([]string, error) {
+	res := []string{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		versions := worksiteBucket(tx, worksite)
+		if versions == nil {
+			return fmt.Errorf("unknown worksite '%s'", worksite)
+		}
+		return versions.ForEach(func(k, v []byte) error {
+			res = append(res, string(k))
+			return nil
+		})
+	})
+	sort.Strings(res)
+	return res, err
+}
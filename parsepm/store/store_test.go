@@ -0,0 +1,97 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/zone"
+)
+
+// newTestZone returns a zone with one PBO (PT 1) fed by SRO through CABLE 1
+func newTestZone() *zone.Zone {
+	z := zone.New()
+	pt := node.NewNode()
+	pt.PtName = "PT 1"
+	pt.LocationType = "PBO"
+	pt.DistFromPM = 120
+	pt.Operation["Attente"] = 4
+	tr := node.NewTroncon("CABLE 1")
+	tr.Capa = 12
+	tr.AerialLength = 100
+	tr.NodeDest = pt
+	pt.TronconIn = tr
+	z.Sro.AddChild(pt)
+	z.Sro.Operation["Epissure->CABLE 1"] = 12
+	z.Nodes.Add(pt)
+	z.Troncons.Add(tr)
+	c := node.NewCable(tr)
+	c.AddTroncon(tr, 0)
+	z.Cables.Add(c)
+	pt.AddUnknownOperation("Epi?", 2)
+	z.Diagnostics = append(z.Diagnostics, zone.Diagnostic{Source: "ROP.xlsx", Cell: "I3", Severity: zone.SeverityFatal, PtName: "PT 2", Msg: "troncon already has a destination node"})
+	z.Paths = append(z.Paths, &zone.OpticalPath{
+		Drawer: "TIROIR_1/A/01",
+		Fiber:  "1",
+		Route:  []zone.PathStep{{Node: z.Sro}, {Node: pt, Troncon: tr, Tube: "T1", Ope: opvocab.OpWaiting}},
+	})
+	return z
+}
+
+func TestStore(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Save("SRO 1", "v1", newTestZone()); err != nil {
+		t.Fatalf("Save returned unexpected error: %s", err.Error())
+	}
+	z2 := newTestZone()
+	z2.Nodes["PT 1"].BPEType = "PBO 12"
+	if _, err := s.Save("SRO 1", "v2", z2); err != nil {
+		t.Fatalf("Save returned unexpected error: %s", err.Error())
+	}
+	if versions, err := s.Versions("SRO 1"); err != nil || len(versions) != 2 {
+		t.Errorf("Versions returned unexpected %v, %v", versions, err)
+	}
+
+	z := zone.New()
+	zr, err := s.LoadZone("SRO 1", "", z)
+	if err != nil {
+		t.Fatalf("LoadZone returned unexpected error: %s", err.Error())
+	}
+	pt := z.Nodes["PT 1"]
+	if zr.Version != "v2" || pt == nil || pt.BPEType != "PBO 12" {
+		t.Fatalf("LoadZone should restore last saved version")
+	}
+	if pt.TronconIn != z.Troncons["CABLE 1"] || pt.TronconIn.NodeSource != z.Sro || z.Sro.Children[0] != pt || z.Cables[0].Troncons[0] != pt.TronconIn {
+		t.Errorf("restored zone links are inconsistent")
+	}
+
+	if nodes := zr.NodesByType("PBO"); len(nodes) != 1 || nodes[0].PtName != "PT 1" {
+		t.Errorf("NodesByType returned unexpected %v", nodes)
+	}
+	if troncons := zr.TronconsByCapa(12); len(troncons) != 1 {
+		t.Errorf("TronconsByCapa returned unexpected %v", troncons)
+	}
+	if capas := zr.TronconCapas(); len(capas) != 1 || capas[0] != 12 {
+		t.Errorf("TronconCapas returned unexpected %v", capas)
+	}
+	if pt.UnknownOperations["Epi?"] != 2 {
+		t.Errorf("unknown operations were not restored")
+	}
+	if path, err := z.TracePath("TIROIR_1/A/01"); err != nil || path.End() != pt || path.Steps[1].Troncon != pt.TronconIn || path.Fiber != "1" {
+		t.Errorf("optical paths were not restored")
+	}
+
+	if len(z.Diagnostics) != 1 || z.Diagnostics[0].Severity != zone.SeverityFatal || z.Diagnostics[0].Cell != "I3" {
+		t.Errorf("parsing diagnostics were not restored: %v", z.Diagnostics)
+	}
+
+	if _, err := s.Load("SRO 2", ""); err == nil {
+		t.Errorf("Load should fail on unknown worksite")
+	}
+}
@@ -0,0 +1,256 @@
+package zone
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/tealeg/xlsx"
+)
+
+// Bill of materials categories, in listing order
+const (
+	MaterialBox             string = "Boîtier"
+	MaterialCassette        string = "Cassette"
+	MaterialSpliceProtector string = "Protection épissure"
+	MaterialAccessory       string = "Accessoire"
+	MaterialCable           string = "Câble"
+)
+
+var materialCategories = []string{MaterialBox, MaterialCassette, MaterialSpliceProtector, MaterialAccessory, MaterialCable}
+
+// Article describes a procurement article
+type Article struct {
+	Designation string `json:"designation"`
+	Unit        string `json:"unit"` // "u" if empty ("m" for cables)
+}
+
+// BoxMaterial describes the material needed per box of a given BPE model
+type BoxMaterial struct {
+	Article            string             `json:"article"`            // box article reference (BPE model if empty)
+	Cassette           string             `json:"cassette"`           // cassette article reference (no cassette if empty)
+	SplicesPerCassette int                `json:"splicesPerCassette"` // number of splices held by a cassette
+	SpliceProtector    string             `json:"spliceProtector"`    // splice protector article reference, one per splice (none if empty)
+	Accessories        map[string]float64 `json:"accessories"`        // quantity per box, by accessory article reference
+}
+
+// CableMaterial describes the article used for a given cable type
+type CableMaterial struct {
+	Article string  `json:"article"` // cable article reference (cable type if empty)
+	Margin  float64 `json:"margin"`  // extra length ratio to be ordered (ex: 0.05 for 5%)
+}
+
+// MaterialTable is the reference table used to derive the bill of materials from zone boxes and cables
+type MaterialTable struct {
+	Articles map[string]Article       `json:"articles"` // by article reference
+	Boxes    map[string]BoxMaterial   `json:"boxes"`    // by BPE model (BPEType)
+	Cables   map[string]CableMaterial `json:"cables"`   // by cable type (capacity string, ex: 12FO, for cables without type)
+}
+
+// LoadMaterialTable reads the material reference table from given JSON file
+func LoadMaterialTable(file string) (MaterialTable, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return MaterialTable{}, err
+	}
+	mt := MaterialTable{}
+	err = json.Unmarshal(content, &mt)
+	if err != nil {
+		return MaterialTable{}, fmt.Errorf("could not parse material table file '%s': %s", filepath.Base(file), err.Error())
+	}
+	return mt, nil
+}
+
+// Material is a bill of materials line : quantity needed for an article
+type Material struct {
+	Category     string
+	Ref          string
+	Designation  string
+	Unit         string
+	Quantity     float64
+	Unreferenced bool // box model or cable type not found in material table (article is named after it)
+}
+
+// Materials is a bill of materials, sorted by category and article reference
+type Materials []*Material
+
+// BillOfMaterials returns the material needed by zone boxes (box, cassettes, splice protectors and accessories, according to BPE model
+// and splice count) and cables (ordered length, according to cable type), as defined by zone MaterialTable
+func (z *Zone) BillOfMaterials() Materials {
+	mt := z.MaterialTable
+	items := map[string]*Material{}
+	add := func(category, ref, unit string, qty float64, unreferenced bool) {
+		if ref == "" || qty == 0 {
+			return
+		}
+		m, found := items[category+"\t"+ref]
+		if !found {
+			m = &Material{Category: category, Ref: ref, Unit: unit, Unreferenced: unreferenced}
+			if a, found := mt.Articles[ref]; found {
+				m.Designation = a.Designation
+				if a.Unit != "" {
+					m.Unit = a.Unit
+				}
+			}
+			items[category+"\t"+ref] = m
+		}
+		m.Quantity += qty
+	}
+
+	for _, n := range z.Nodes {
+		if n.BPEType == "" || n.BPEType == "PM" {
+			continue
+		}
+		box, found := mt.Boxes[n.BPEType]
+		if !found {
+			add(MaterialBox, n.BPEType, "u", 1, true)
+			continue
+		}
+		ref := box.Article
+		if ref == "" {
+			ref = n.BPEType
+		}
+		add(MaterialBox, ref, "u", 1, false)
+		nbSplice, _ := n.GetNumbers()
+		if box.Cassette != "" && box.SplicesPerCassette > 0 && nbSplice > 0 {
+			add(MaterialCassette, box.Cassette, "u", math.Ceil(float64(nbSplice)/float64(box.SplicesPerCassette)), false)
+		}
+		add(MaterialSpliceProtector, box.SpliceProtector, "u", float64(nbSplice), false)
+		for acc, qty := range box.Accessories {
+			add(MaterialAccessory, acc, "u", qty, false)
+		}
+	}
+
+	lengths := map[string]int{}
+	for _, c := range z.Cables {
+		cableType := c.CableType()
+		if cableType == "" {
+			cableType = fmt.Sprintf("%dFO", c.Capa)
+		}
+		lengths[cableType] += c.RequiredLength()
+	}
+	for cableType, length := range lengths {
+		cable, found := mt.Cables[cableType]
+		ref := cable.Article
+		if ref == "" {
+			ref = cableType
+		}
+		add(MaterialCable, ref, "m", math.Ceil(float64(length)*(1+cable.Margin)), !found)
+	}
+
+	res := make(Materials, 0, len(items))
+	for _, m := range items {
+		res = append(res, m)
+	}
+	catOrder := map[string]int{}
+	for i, cat := range materialCategories {
+		catOrder[cat] = i
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Category != res[j].Category {
+			return catOrder[res[i].Category] < catOrder[res[j].Category]
+		}
+		return res[i].Ref < res[j].Ref
+	})
+	return res
+}
+
+// Unreferenced returns the receiver materials whose box model or cable type is not found in material table
+func (ms Materials) Unreferenced() Materials {
+	res := Materials{}
+	for _, m := range ms {
+		if m.Unreferenced {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+func (m *Material) quantityString() string {
+	return strconv.FormatFloat(m.Quantity, 'f', -1, 64)
+}
+
+// String returns a one line description of the receiver material
+func (m *Material) String() string {
+	return fmt.Sprintf("%s '%s' : %s %s", m.Category, m.Ref, m.quantityString(), m.Unit)
+}
+
+// WriteCSV writes the receiver materials as ';' separated CSV (with header row) to given writer
+func (ms Materials) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	err := cw.Write([]string{"Catégorie", "Article", "Désignation", "Unité", "Quantité"})
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		err = cw.Write([]string{m.Category, m.Ref, m.Designation, m.Unit, m.quantityString()})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// addMaterialSheet adds a Matériel sheet listing the receiver materials (unreferenced ones are highlighted)
+func (ms Materials) addMaterialSheet(xls *xlsx.File) error {
+	sheet, err := xls.AddSheet("Matériel")
+	if err != nil {
+		return err
+	}
+	cols := []col{
+		{"Catégorie", 20},
+		{"Article", 36},
+		{"Désignation", 50},
+		{"Unité", 8},
+		{"Quantité", 12},
+	}
+	addHeaderRow(sheet, cols)
+	for _, m := range ms {
+		r := sheet.AddRow()
+		r.AddCell().SetString(m.Category)
+		r.AddCell().SetString(m.Ref)
+		r.AddCell().SetString(m.Designation)
+		r.AddCell().SetString(m.Unit)
+		r.AddCell().SetFloat(m.Quantity)
+		if !m.Unreferenced {
+			continue
+		}
+		fillRow(r, colorOrange)
+	}
+	return nil
+}
+
+// WriteBOM writes zone bill of materials (see BillOfMaterials) in <dir>/<name>_materiel.xlsx and <dir>/<name>_materiel.csv, and returns it
+func (z *Zone) WriteBOM(dir, name string) (Materials, error) {
+	ms := z.BillOfMaterials()
+	if len(ms) == 0 {
+		return nil, fmt.Errorf("no material found in zone")
+	}
+
+	xlsx.SetDefaultFont(11, "Calibri")
+	xls := xlsx.NewFile()
+	err := ms.addMaterialSheet(xls)
+	if err != nil {
+		return nil, err
+	}
+	err = writeXLSFile(xls, filepath.Join(dir, name+"_materiel.xlsx"))
+	if err != nil {
+		return nil, err
+	}
+
+	of, err := os.Create(filepath.Join(dir, name+"_materiel.csv"))
+	if err != nil {
+		return nil, err
+	}
+	defer of.Close()
+	return ms, ms.WriteCSV(of)
+}
@@ -0,0 +1,72 @@
+package zone
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+)
+
+func TestZone_BillOfMaterials(t *testing.T) {
+	z := newTestDrumZone(
+		testCable{"CABLE 144", 144, 300},
+		testCable{"CABLE 144", 144, 700},
+		testCable{"CABLE 12", 12, 200},
+	)
+	for i, bpeType := range []string{"TENIO T1", "TENIO T1", "PB 3M", "PM"} {
+		n := node.NewNode()
+		n.PtName = "PT " + string(rune('1'+i))
+		n.BPEType = bpeType
+		n.Operation["Epissure->CABLE A"] = 5 * (i + 1)
+		n.Operation["Attente"] = 4
+		z.Nodes[n.PtName] = n
+	}
+	z.MaterialTable = MaterialTable{
+		Articles: map[string]Article{
+			"CASS-12":        {Designation: "Cassette 12 épissures"},
+			"CABLE_144FO_M6": {Designation: "Câble 144 FO", Unit: "ml"},
+		},
+		Boxes: map[string]BoxMaterial{
+			"TENIO T1": {Article: "TENIO-T1", Cassette: "CASS-12", SplicesPerCassette: 12, SpliceProtector: "SMOUV-45", Accessories: map[string]float64{"KIT-ETANCH": 2}},
+		},
+		Cables: map[string]CableMaterial{
+			"CABLE 144": {Article: "CABLE_144FO_M6", Margin: 0.05},
+		},
+	}
+
+	ms := z.BillOfMaterials()
+	expected := []string{
+		"Boîtier 'PB 3M' : 1 u",
+		"Boîtier 'TENIO-T1' : 2 u",
+		"Cassette 'CASS-12' : 2 u", // 5 and 10 splices
+		"Protection épissure 'SMOUV-45' : 15 u",
+		"Accessoire 'KIT-ETANCH' : 4 u",
+		"Câble 'CABLE 12' : 200 m",
+		"Câble 'CABLE_144FO_M6' : 1050 ml",
+	}
+	if len(ms) != len(expected) {
+		t.Fatalf("unexpected bill of materials %v", ms)
+	}
+	for i, m := range ms {
+		if m.String() != expected[i] {
+			t.Errorf("material %d is %s instead of %s", i, m.String(), expected[i])
+		}
+	}
+	if ms[5].Designation != "" || ms[6].Designation != "Câble 144 FO" {
+		t.Errorf("unexpected designations '%s', '%s'", ms[5].Designation, ms[6].Designation)
+	}
+	if unref := ms.Unreferenced(); len(unref) != 2 || unref[0].Ref != "PB 3M" || unref[1].Ref != "CABLE 12" {
+		t.Errorf("unexpected unreferenced materials %v", unref)
+	}
+
+	buf := &bytes.Buffer{}
+	err := ms.WriteCSV(buf)
+	if err != nil {
+		t.Fatalf("WriteCSV returned unexpected: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected)+1 || lines[3] != "Cassette;CASS-12;Cassette 12 épissures;u;2" {
+		t.Errorf("unexpected CSV content:\n%s", buf.String())
+	}
+}
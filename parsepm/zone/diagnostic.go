@@ -0,0 +1,101 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota // parsing goes on, result is unaffected
+	SeverityError                   // parsing goes on, result may be inaccurate
+	SeverityFatal                   // related block could not be processed
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "Warning"
+	case SeverityError:
+		return "Error"
+	case SeverityFatal:
+		return "Fatal"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText implements encoding.TextMarshaler (severity is written as its name in JSON)
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler (severity is read from its name in JSON)
+func (s *Severity) UnmarshalText(text []byte) error {
+	for _, sev := range []Severity{SeverityWarning, SeverityError, SeverityFatal} {
+		if sev.String() == string(text) {
+			*s = sev
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity '%s'", string(text))
+}
+
+// Diagnostic describes an inconsistency found while parsing a file (or while checking zone consistency)
+type Diagnostic struct {
+	Source   string   `json:"source"` // file name or check name
+	Cell     string   `json:"cell"`   // cell reference (ex: F12)
+	Severity Severity `json:"severity"`
+	PtName   string   `json:"ptName"`  // involved node (if any)
+	Troncon  string   `json:"troncon"` // involved troncon (if any)
+	Msg      string   `json:"msg"`
+}
+
+func (d Diagnostic) String() string {
+	res := d.Severity.String()
+	if d.Source != "" {
+		res += " " + d.Source
+	}
+	if d.Cell != "" {
+		res += " pos " + d.Cell
+	}
+	if d.PtName != "" {
+		res += fmt.Sprintf(" node '%s'", d.PtName)
+	}
+	if d.Troncon != "" {
+		res += fmt.Sprintf(" troncon '%s'", d.Troncon)
+	}
+	return res + " : " + d.Msg
+}
+
+type Diagnostics []Diagnostic
+
+// HasFatal returns true if receiver contains at least one fatal Diagnostic
+func (ds Diagnostics) HasFatal() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityFatal {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns Diagnostics having at least given severity
+func (ds Diagnostics) Filter(minSeverity Severity) Diagnostics {
+	res := Diagnostics{}
+	for _, d := range ds {
+		if d.Severity >= minSeverity {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// Error implements error interface, listing all receiver diagnostics (one per line)
+func (ds Diagnostics) Error() string {
+	msgs := make([]string, len(ds))
+	for i, d := range ds {
+		msgs[i] = d.String()
+	}
+	return strings.Join(msgs, "\n")
+}
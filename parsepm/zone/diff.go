@@ -0,0 +1,225 @@
+package zone
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	changeAdded    string = "Ajout"
+	changeRemoved  string = "Suppression"
+	changeModified string = "Modification"
+
+	kindNode        string = "Noeud"
+	kindTroncon     string = "Tronçon"
+	kindOperation   string = "Opération"
+	kindMeasurement string = "Mesure"
+)
+
+// Change describes a difference between two design versions of a zone
+type Change struct {
+	Kind   string // Noeud, Tronçon, Opération or Mesure
+	Name   string // PT or troncon name
+	Item   string // changed attribute (or operation name)
+	Change string // Ajout, Suppression or Modification
+	Old    string
+	New    string
+}
+
+func (c Change) String() string {
+	res := fmt.Sprintf("%s %s '%s'", c.Change, c.Kind, c.Name)
+	if c.Item != "" {
+		res += " " + c.Item
+	}
+	switch c.Change {
+	case changeAdded:
+		return res + fmt.Sprintf(" (%s)", c.New)
+	case changeRemoved:
+		return res + fmt.Sprintf(" (%s)", c.Old)
+	}
+	return res + fmt.Sprintf(" : %s -> %s", c.Old, c.New)
+}
+
+type Changes []Change
+
+// Diff returns all billable work differences between old and new design versions of a zone :
+// added and removed nodes, changed BPE types, troncons capacity and lengths, nodes operation counts and measurement targets
+func Diff(old, new *Zone) Changes {
+	res := Changes{}
+	res = append(res, diffNodes(old.allNodes(), new.allNodes())...)
+	res = append(res, diffTroncons(old.Troncons, new.Troncons)...)
+	return res
+}
+
+func modified(kind, name, item string, old, new interface{}) Change {
+	return Change{Kind: kind, Name: name, Item: item, Change: changeModified, Old: fmt.Sprint(old), New: fmt.Sprint(new)}
+}
+
+func sortedKeys(keys map[string]bool) []string {
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func diffNodes(old, new node.Nodes) (res Changes) {
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range new {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		on, nn := old[name], new[name]
+		switch {
+		case on == nil:
+			res = append(res, Change{Kind: kindNode, Name: name, Change: changeAdded, New: nn.BPEType})
+			continue
+		case nn == nil:
+			res = append(res, Change{Kind: kindNode, Name: name, Change: changeRemoved, Old: on.BPEType})
+			continue
+		}
+		if on.BPEType != nn.BPEType {
+			res = append(res, modified(kindNode, name, "Type Boitier", on.BPEType, nn.BPEType))
+		}
+		res = append(res, diffOperations(on, nn)...)
+		res = append(res, diffMeasurement(on, nn)...)
+	}
+	return
+}
+
+func diffOperations(on, nn *node.Node) (res Changes) {
+	opes := map[string]bool{}
+	for ope := range on.Operation {
+		opes[ope] = true
+	}
+	for ope := range nn.Operation {
+		opes[ope] = true
+	}
+	for _, ope := range sortedKeys(opes) {
+		oNb, oFound := on.Operation[ope]
+		nNb, nFound := nn.Operation[ope]
+		switch {
+		case !oFound:
+			res = append(res, Change{Kind: kindOperation, Name: nn.PtName, Item: ope, Change: changeAdded, New: strconv.Itoa(nNb)})
+		case !nFound:
+			res = append(res, Change{Kind: kindOperation, Name: on.PtName, Item: ope, Change: changeRemoved, Old: strconv.Itoa(oNb)})
+		case oNb != nNb:
+			res = append(res, modified(kindOperation, nn.PtName, ope, oNb, nNb))
+		}
+	}
+	return
+}
+
+func diffMeasurement(on, nn *node.Node) (res Changes) {
+	oFiber, nFiber := on.GetToBeMeasuredFiber(), nn.GetToBeMeasuredFiber()
+	switch {
+	case oFiber == 0 && nFiber == 0:
+		return
+	case oFiber == 0:
+		return Changes{{Kind: kindMeasurement, Name: nn.PtName, Item: "Nb Fibres", Change: changeAdded, New: strconv.Itoa(nFiber)}}
+	case nFiber == 0:
+		return Changes{{Kind: kindMeasurement, Name: on.PtName, Item: "Nb Fibres", Change: changeRemoved, Old: strconv.Itoa(oFiber)}}
+	}
+	if oFiber != nFiber {
+		res = append(res, modified(kindMeasurement, nn.PtName, "Nb Fibres", oFiber, nFiber))
+	}
+	if len(on.SplicePT) != len(nn.SplicePT) {
+		res = append(res, modified(kindMeasurement, nn.PtName, "Nb Episs.", len(on.SplicePT), len(nn.SplicePT)))
+	}
+	if on.DistFromPM != nn.DistFromPM {
+		res = append(res, modified(kindMeasurement, nn.PtName, "Distance", on.DistFromPM, nn.DistFromPM))
+	}
+	return
+}
+
+func diffTroncons(old, new node.Troncons) (res Changes) {
+	names := map[string]bool{}
+	for name := range old {
+		names[name] = true
+	}
+	for name := range new {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		ot, nt := old[name], new[name]
+		switch {
+		case ot == nil:
+			res = append(res, Change{Kind: kindTroncon, Name: name, Change: changeAdded, New: nt.CapaString()})
+			continue
+		case nt == nil:
+			res = append(res, Change{Kind: kindTroncon, Name: name, Change: changeRemoved, Old: ot.CapaString()})
+			continue
+		}
+		if ot.Capa != nt.Capa {
+			res = append(res, modified(kindTroncon, name, "Taille", ot.CapaString(), nt.CapaString()))
+		}
+		lengths := []struct {
+			item     string
+			old, new int
+		}{
+			{"Love", ot.LoveLength, nt.LoveLength},
+			{"Souterrain", ot.UndergroundLength, nt.UndergroundLength},
+			{"Aérien", ot.AerialLength, nt.AerialLength},
+			{"Façade", ot.FacadeLength, nt.FacadeLength},
+		}
+		for _, l := range lengths {
+			if l.old != l.new {
+				res = append(res, modified(kindTroncon, name, l.item, l.old, l.new))
+			}
+		}
+	}
+	return
+}
+
+// WriteXLS writes receiver changes as a XLSx report in given file
+func (cs Changes) WriteXLS(file string) error {
+	xlsx.SetDefaultFont(11, "Calibri")
+	xls := xlsx.NewFile()
+	sheet, err := xls.AddSheet("Différences")
+	if err != nil {
+		return err
+	}
+
+	cols := []col{
+		{"Type", 12},
+		{"Nom", 25},
+		{"Elément", 30},
+		{"Changement", 15},
+		{"Ancien", 15},
+		{"Nouveau", 15},
+	}
+	addHeaderRow(sheet, cols)
+
+	colors := map[string]string{
+		changeAdded:    colorGreen,
+		changeRemoved:  colorRed,
+		changeModified: colorBlue,
+	}
+	for _, c := range cs {
+		r := sheet.AddRow()
+		r.AddCell().SetString(c.Kind)
+		r.AddCell().SetString(c.Name)
+		r.AddCell().SetString(c.Item)
+		r.AddCell().SetString(c.Change)
+		r.AddCell().SetString(c.Old)
+		r.AddCell().SetString(c.New)
+
+		fillRow(r, colors[c.Change])
+	}
+
+	of, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer of.Close()
+	return xls.Write(of)
+}
@@ -0,0 +1,48 @@
+package zone
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), old).ParseRop()
+	if changes := Diff(old, old); len(changes) > 0 {
+		t.Fatalf("Diff returned unexpected changes for same zone: %v", changes)
+	}
+
+	new := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "150"), new).ParseRop()
+	pt := new.Nodes["PT 1"]
+	pt.BPEType = "PBO 12"
+	pt.TronconIn.Capa = 24
+	pt.Operation["Epissure->CABLE 2"] = 2
+	changes := Diff(old, new)
+
+	expected := map[string]Change{
+		kindNode + " Type Boitier":           {Old: old.Nodes["PT 1"].BPEType, New: "PBO 12"},
+		kindTroncon + " Taille":              {Old: old.Nodes["PT 1"].TronconIn.CapaString(), New: pt.TronconIn.CapaString()},
+		kindOperation + " Epissure->CABLE 2": {Change: changeAdded, New: "2"},
+		kindMeasurement + " Distance":        {Old: "120", New: "150"},
+	}
+	for _, c := range changes {
+		key := c.Kind + " " + c.Item
+		exp, found := expected[key]
+		if !found {
+			continue
+		}
+		delete(expected, key)
+		if c.Old != exp.Old || c.New != exp.New {
+			t.Errorf("unexpected change '%s'", c.String())
+		}
+	}
+	for key := range expected {
+		t.Errorf("missing '%s' change in: %v", key, changes)
+	}
+
+	err := changes.WriteXLS(filepath.Join(t.TempDir(), "test_diff.xlsx"))
+	if err != nil {
+		t.Fatalf("WriteXLS returned unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,311 @@
+package zone
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/chantiersalsace/xlsreader"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	checkDrum string = "Tourets"
+
+	drumHeaderMaxRow int = 10 // drum inventory header row is searched among first rows
+)
+
+// Drum is a cable drum (touret) of the logistics inventory, along with the cables allocated to it
+type Drum struct {
+	Name      string
+	CableType string // cable reference (any if empty)
+	Capa      int    // cable fiber count (any if 0)
+	Length    int    // available cable length (m)
+	Cables    []*node.Cable
+}
+
+// Allocated returns the cable length (m) allocated on the receiver
+func (d *Drum) Allocated() int {
+	res := 0
+	for _, c := range d.Cables {
+		res += c.RequiredLength()
+	}
+	return res
+}
+
+// Remaining returns the cable length (m) left on the receiver once allocated cables are cut
+func (d *Drum) Remaining() int {
+	return d.Length - d.Allocated()
+}
+
+// Accepts returns true if given cable can be cut from the receiver : same cable type and fiber count, when defined on both sides
+func (d *Drum) Accepts(c *node.Cable) bool {
+	if d.CableType != "" && c.CableType() != "" && !strings.EqualFold(strings.TrimSpace(d.CableType), strings.TrimSpace(c.CableType())) {
+		return false
+	}
+	if d.Capa > 0 && c.Capa > 0 && d.Capa != c.Capa {
+		return false
+	}
+	return true
+}
+
+// drumColumn returns the drum field designated by given inventory header value, or "" if header is not a drum one
+func drumColumn(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	switch {
+	case strings.Contains(header, "restant"), strings.Contains(header, "remaining"):
+		return "remaining"
+	case strings.HasPrefix(header, "longueur"), strings.HasPrefix(header, "length"):
+		return "length"
+	case strings.HasPrefix(header, "touret"), strings.HasPrefix(header, "drum"), strings.HasPrefix(header, "n°"):
+		return "name"
+	case strings.HasPrefix(header, "type"), strings.HasPrefix(header, "cable"), strings.HasPrefix(header, "câble"):
+		return "type"
+	case strings.HasPrefix(header, "capa"), header == "fo", header == "nb fo":
+		return "capa"
+	}
+	return ""
+}
+
+// ParseDrumsXLS reads the cable drums inventory from given file first sheet : a header row declares drum name (Touret...), cable type (Type...),
+// fiber count (Capa...) and remaining length (...restant... or Longueur...) columns. Rows with invalid values are reported and skipped
+func (z *Zone) ParseDrumsXLS(file string) ([]*Drum, error) {
+	xls, err := xlsreader.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(xls.Sheets) == 0 {
+		return nil, fmt.Errorf("no sheet found")
+	}
+	baseFile := filepath.Base(file)
+	sheet := xls.Sheets[0]
+
+	cols := map[string]int{}
+	headerRow := -1
+	for row := 0; row < sheet.MaxRow && row < drumHeaderMaxRow && headerRow < 0; row++ {
+		found := map[string]int{}
+		for col := 0; col < sheet.MaxCol; col++ {
+			if field := drumColumn(sheet.Cell(row, col).Value); field != "" {
+				if _, exists := found[field]; !exists {
+					found[field] = col
+				}
+			}
+		}
+		_, hasName := found["name"]
+		_, hasLength := found["length"]
+		_, hasRemaining := found["remaining"]
+		if hasName && (hasLength || hasRemaining) {
+			cols, headerRow = found, row
+		}
+	}
+	if headerRow < 0 {
+		return nil, fmt.Errorf("could not find header row with drum name and length columns")
+	}
+	colLength, found := cols["remaining"]
+	if !found {
+		colLength = cols["length"]
+	}
+
+	value := func(row int, field string) string {
+		col, found := cols[field]
+		if !found {
+			return ""
+		}
+		return strings.TrimSpace(sheet.Cell(row, col).Value)
+	}
+	drums := []*Drum{}
+	for row := headerRow + 1; row < sheet.MaxRow; row++ {
+		name := value(row, "name")
+		if name == "" {
+			continue
+		}
+		length, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(sheet.Cell(row, colLength).Value), ",", ".", 1), 64)
+		if err != nil || length < 0 {
+			z.report(baseFile, xlsx.GetCellIDStringFromCoords(colLength, row), SeverityWarning, "", "", fmt.Sprintf("invalid length for drum '%s'. Skipping", name))
+			continue
+		}
+		d := &Drum{Name: name, CableType: value(row, "type"), Length: int(math.Floor(length))}
+		if capa := value(row, "capa"); capa != "" {
+			d.Capa, err = strconv.Atoi(strings.TrimSuffix(strings.ToUpper(capa), "FO"))
+			if err != nil {
+				z.report(baseFile, xlsx.GetCellIDStringFromCoords(cols["capa"], row), SeverityWarning, "", "", fmt.Sprintf("invalid capacity '%s' for drum '%s'. Skipping", capa, name))
+				continue
+			}
+		}
+		drums = append(drums, d)
+	}
+	return drums, nil
+}
+
+// AllocateDrums assigns zone cables to given drums so as to limit drum offcuts (best fit decreasing : longest cables first, each one on the
+// compatible drum leaving the shortest remaining length). Drum cables are then sorted in zone order (pulling plan).
+//
+// Cables fitting on no drum are returned, and reported as zone Diagnostics
+func (z *Zone) AllocateDrums(drums []*Drum) []*node.Cable {
+	index := map[*node.Cable]int{}
+	cables := make([]*node.Cable, len(z.Cables))
+	for i, c := range z.Cables {
+		index[c] = i
+		cables[i] = c
+	}
+	for _, d := range drums {
+		d.Cables = nil
+	}
+	sort.SliceStable(cables, func(i, j int) bool {
+		return cables[i].RequiredLength() > cables[j].RequiredLength()
+	})
+
+	unallocated := []*node.Cable{}
+	for _, c := range cables {
+		length := c.RequiredLength()
+		var best *Drum
+		for _, d := range drums {
+			if !d.Accepts(c) || d.Remaining() < length {
+				continue
+			}
+			if best == nil || d.Remaining() < best.Remaining() {
+				best = d
+			}
+		}
+		if best == nil {
+			unallocated = append(unallocated, c)
+			continue
+		}
+		best.Cables = append(best.Cables, c)
+	}
+
+	byZoneOrder := func(cs []*node.Cable) {
+		sort.Slice(cs, func(i, j int) bool {
+			return index[cs[i]] < index[cs[j]]
+		})
+	}
+	for _, d := range drums {
+		byZoneOrder(d.Cables)
+	}
+	byZoneOrder(unallocated)
+	for _, c := range unallocated {
+		z.report(checkDrum, "", SeverityError, c.Troncons[0].NodeSource.PtName, c.Troncons[0].Name, fmt.Sprintf("no drum with %dm of cable '%s' (%dFO) available", c.RequiredLength(), c.CableType(), c.Capa))
+	}
+	return unallocated
+}
+
+// drumSheetName returns a valid and unique sheet name for given drum
+func drumSheetName(xls *xlsx.File, d *Drum) string {
+	name := "Touret " + strings.NewReplacer(":", "_", "\\", "_", "/", "_", "?", "_", "*", "_", "[", "(", "]", ")").Replace(d.Name)
+	if runes := []rune(name); len(runes) > 28 {
+		name = string(runes[:28])
+	}
+	res := name
+	for i := 2; xls.Sheet[res] != nil; i++ {
+		res = fmt.Sprintf("%s %d", name, i)
+	}
+	return res
+}
+
+// WriteDrumsXLS allocates zone cables to given drums (see AllocateDrums), and writes the resulting pulling plan in <dir>/<name>_tourets.xlsx :
+// a Synthèse sheet with one row per drum, one sheet per used drum listing its cables in pulling order, and a Non affectés sheet listing cables fitting on no drum.
+//
+// Cables fitting on no drum are returned
+func (z *Zone) WriteDrumsXLS(dir, name string, drums []*Drum) ([]*node.Cable, error) {
+	unallocated := z.AllocateDrums(drums)
+
+	xlsx.SetDefaultFont(11, "Calibri")
+	xls := xlsx.NewFile()
+	sheet, err := xls.AddSheet("Synthèse")
+	if err != nil {
+		return nil, err
+	}
+	addHeaderRow(sheet, []col{
+		{"Touret", 20},
+		{"Type Cable", 36},
+		{"Capa", 8},
+		{"Longueur dispo", 12},
+		{"Longueur allouée", 12},
+		{"Nb Câbles", 10},
+		{"Reste", 12},
+	})
+	for _, d := range drums {
+		r := sheet.AddRow()
+		r.AddCell().SetString(d.Name)
+		r.AddCell().SetString(d.CableType)
+		r.AddCell().SetInt(d.Capa)
+		r.AddCell().SetInt(d.Length)
+		r.AddCell().SetInt(d.Allocated())
+		r.AddCell().SetInt(len(d.Cables))
+		r.AddCell().SetInt(d.Remaining())
+		if len(d.Cables) == 0 {
+			continue
+		}
+		fillRow(r, colorGreen)
+	}
+
+	cableCols := []col{
+		{"Ordre", 8},
+		{"Type Cable", 36},
+		{"Tronçon", 12},
+		{"PT Départ", 15},
+		{"Adr. Départ", 40},
+		{"PT Arrivée", 15},
+		{"Adr. Arrivée", 40},
+		{"Longueur", 12},
+		{"Love", 10},
+		{"Souterrain", 10},
+		{"Aérien", 10},
+		{"Façade", 10},
+	}
+	writeCables := func(sheet *xlsx.Sheet, d *Drum, cables []*node.Cable) {
+		cols := cableCols
+		if d != nil {
+			cols = append(cols, col{"Reste touret", 12})
+		}
+		addHeaderRow(sheet, cols)
+		remaining := 0
+		if d != nil {
+			remaining = d.Length
+		}
+		for i, c := range cables {
+			nBeg := c.Troncons[0].NodeSource
+			nEnd := c.LastTroncon().NodeDest
+			lov, under, aer, fac := c.GetLenths()
+			r := sheet.AddRow()
+			r.AddCell().SetInt(i + 1)
+			r.AddCell().SetString(c.CableType())
+			r.AddCell().SetString(c.Troncons[0].Name)
+			r.AddCell().SetString(nBeg.PtName)
+			r.AddCell().SetString(nBeg.Address)
+			r.AddCell().SetString(nEnd.PtName)
+			r.AddCell().SetString(nEnd.Address)
+			r.AddCell().SetInt(c.RequiredLength())
+			r.AddCell().SetInt(lov)
+			r.AddCell().SetInt(under)
+			r.AddCell().SetInt(aer)
+			r.AddCell().SetInt(fac)
+			if d != nil {
+				remaining -= c.RequiredLength()
+				r.AddCell().SetInt(remaining)
+			}
+		}
+	}
+	for _, d := range drums {
+		if len(d.Cables) == 0 {
+			continue
+		}
+		sheet, err := xls.AddSheet(drumSheetName(xls, d))
+		if err != nil {
+			return nil, err
+		}
+		writeCables(sheet, d, d.Cables)
+	}
+	if len(unallocated) > 0 {
+		sheet, err := xls.AddSheet("Non affectés")
+		if err != nil {
+			return nil, err
+		}
+		writeCables(sheet, nil, unallocated)
+	}
+	return unallocated, writeXLSFile(xls, filepath.Join(dir, name+"_tourets.xlsx"))
+}
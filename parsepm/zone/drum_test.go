@@ -0,0 +1,140 @@
+package zone
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/tealeg/xlsx"
+)
+
+type testCable struct {
+	cableType string
+	capa      int
+	length    int // underground length
+}
+
+// newTestDrumZone returns a zone with one single troncon cable per given testCable
+func newTestDrumZone(cables ...testCable) *Zone {
+	z := New()
+	for i, c := range cables {
+		tr := node.NewTroncon("TR-" + string(rune('A'+i)))
+		tr.Capa, tr.CableType, tr.UndergroundLength = c.capa, c.cableType, c.length
+		tr.NodeSource, tr.NodeDest = node.NewNode(), node.NewNode()
+		cable := node.NewCable(tr)
+		cable.AddTroncon(tr, 0)
+		z.Cables.Add(cable)
+	}
+	return z
+}
+
+func TestZone_AllocateDrums(t *testing.T) {
+	z := newTestDrumZone(
+		testCable{"CABLE 144", 144, 300},
+		testCable{"CABLE 144", 144, 700},
+		testCable{"CABLE 12", 12, 200},
+		testCable{"CABLE 144", 144, 500},
+		testCable{"CABLE 12", 12, 900},
+	)
+	drums := []*Drum{
+		{Name: "T1", CableType: "cable 144", Length: 1000},
+		{Name: "T2", CableType: "CABLE 144", Capa: 144, Length: 800},
+		{Name: "T3", Capa: 12, Length: 250},
+	}
+	unallocated := z.AllocateDrums(drums)
+
+	// 700 -> T2 (800), 500 -> T1 (1000), 300 -> T1 (500 left), 200 -> T3
+	expected := map[string][]string{
+		"T1": {"TR-A", "TR-D"},
+		"T2": {"TR-B"},
+		"T3": {"TR-C"},
+	}
+	for _, d := range drums {
+		names := []string{}
+		for _, c := range d.Cables {
+			names = append(names, c.Troncons[0].Name)
+		}
+		if len(names) != len(expected[d.Name]) {
+			t.Errorf("drum %s has cables %v instead of %v", d.Name, names, expected[d.Name])
+			continue
+		}
+		for i := range names {
+			if names[i] != expected[d.Name][i] {
+				t.Errorf("drum %s has cables %v instead of %v", d.Name, names, expected[d.Name])
+				break
+			}
+		}
+	}
+	if drums[0].Remaining() != 200 || drums[2].Remaining() != 50 {
+		t.Errorf("unexpected remaining lengths %d, %d", drums[0].Remaining(), drums[2].Remaining())
+	}
+	if len(unallocated) != 1 || unallocated[0].Troncons[0].Name != "TR-E" {
+		t.Fatalf("unexpected unallocated cables %v", unallocated)
+	}
+	if len(z.Diagnostics) != 1 || z.Diagnostics[0].Troncon != "TR-E" || z.Diagnostics[0].Severity != SeverityError {
+		t.Errorf("unexpected diagnostics %v", z.Diagnostics)
+	}
+
+	dir := t.TempDir()
+	_, err := z.WriteDrumsXLS(dir, "PM", drums)
+	if err != nil {
+		t.Fatalf("WriteDrumsXLS returned unexpected: %s", err.Error())
+	}
+	xf, err := xlsx.OpenFile(filepath.Join(dir, "PM_tourets.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Synthèse", "Touret T1", "Touret T2", "Touret T3", "Non affectés"} {
+		if xf.Sheet[name] == nil {
+			t.Errorf("missing sheet '%s'", name)
+		}
+	}
+	if sheet := xf.Sheet["Touret T1"]; sheet != nil && sheet.Cell(2, 12).Value != "200" {
+		t.Errorf("unexpected T1 remaining length '%s'", sheet.Cell(2, 12).Value)
+	}
+}
+
+func TestZone_ParseDrumsXLS(t *testing.T) {
+	xf := xlsx.NewFile()
+	sheet, err := xf.AddSheet("Stock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := [][]string{
+		{"Inventaire tourets"},
+		{},
+		{"N° Touret", "Type Câble", "Capa", "Longueur initiale", "Longueur restante"},
+		{"T1", "CABLE 144", "144FO", "2000", "1250,5"},
+		{"T2", "CABLE 12", "12", "1000", "n/a"},
+		{"", "CABLE 12", "12", "1000", "300"},
+		{"T3", "CABLE 12", "", "1000", "600"},
+	}
+	for r, row := range rows {
+		for c, value := range row {
+			sheet.Cell(r, c).SetString(value)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "Tourets.xlsx")
+	err = xf.Save(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := New()
+	drums, err := z.ParseDrumsXLS(file)
+	if err != nil {
+		t.Fatalf("ParseDrumsXLS returned unexpected: %s", err.Error())
+	}
+	if len(drums) != 2 {
+		t.Fatalf("unexpected number of drums %d", len(drums))
+	}
+	if d := drums[0]; d.Name != "T1" || d.CableType != "CABLE 144" || d.Capa != 144 || d.Length != 1250 {
+		t.Errorf("unexpected drum %+v", d)
+	}
+	if d := drums[1]; d.Name != "T3" || d.Capa != 0 || d.Length != 600 {
+		t.Errorf("unexpected drum %+v", d)
+	}
+	if len(z.Diagnostics) != 1 || z.Diagnostics[0].Cell != "E5" {
+		t.Errorf("unexpected diagnostics %v", z.Diagnostics)
+	}
+}
@@ -0,0 +1,349 @@
+package zone
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+)
+
+const (
+	// map colors (RRGGBB), by node LocationType and troncon PullingType
+	geoColorPM          string = "c0504d"
+	geoColorBPE         string = "4f81bd"
+	geoColorPBO         string = "9bbb59"
+	geoColorUnderground string = "7f6000"
+	geoColorAerial      string = "e46c0a"
+	geoColorFacade      string = "8064a2"
+	geoColorUnknown     string = "7f7f7f"
+)
+
+func geoNodeColor(locationType string) string {
+	switch locationType {
+	case "PM":
+		return geoColorPM
+	case "PBO":
+		return geoColorPBO
+	}
+	return geoColorBPE
+}
+
+func geoTronconColor(pullingType string) string {
+	switch pullingType {
+	case node.PullingUnderground:
+		return geoColorUnderground
+	case node.PullingAerial:
+		return geoColorAerial
+	case node.PullingFacade:
+		return geoColorFacade
+	}
+	return geoColorUnknown
+}
+
+// coordinateColumn returns the coordinate (lat or long) designated by given header value, or "" if header is not a coordinate one
+func coordinateColumn(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	switch {
+	case strings.HasPrefix(header, "lat"):
+		return "lat"
+	case strings.HasPrefix(header, "lon"), strings.HasPrefix(header, "lng"):
+		return "long"
+	}
+	return ""
+}
+
+// parseCoordinate returns the decimal degree value of given string (decimal comma allowed)
+func parseCoordinate(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(value), ",", ".", 1), 64)
+}
+
+// setCoordinates sets given node coordinates, reporting unparsable or out of range values
+func (z *Zone) setCoordinates(n *node.Node, source, cell, lat, long string) {
+	if strings.TrimSpace(lat) == "" && strings.TrimSpace(long) == "" {
+		return
+	}
+	la, errLat := parseCoordinate(lat)
+	lo, errLong := parseCoordinate(long)
+	if errLat != nil || errLong != nil || la < -90 || la > 90 || lo < -180 || lo > 180 {
+		z.report(source, cell, SeverityError, n.PtName, "", fmt.Sprintf("invalid WGS84 coordinates '%s, %s'. Skipping", lat, long))
+		return
+	}
+	n.Lat, n.Long = la, lo
+}
+
+// ParseCoordinatesCSV reads nodes coordinates from given geocoding table : CSV file (',' or ';' separated) with a header row
+// declaring PT name (first column), latitude (LAT...) and longitude (LON... or LNG...) columns, in WGS84 decimal degrees
+func (z *Zone) ParseCoordinatesCSV(file string) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	baseFile := filepath.Base(file)
+
+	r := csv.NewReader(strings.NewReader(string(content)))
+	firstLine := strings.SplitN(string(content), "\n", 2)[0]
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("could not read header row: %s", err.Error())
+	}
+	colLat, colLong := -1, -1
+	for col, value := range header {
+		switch coordinateColumn(value) {
+		case "lat":
+			colLat = col
+		case "long":
+			colLong = col
+		}
+	}
+	if colLat == -1 || colLong == -1 {
+		return fmt.Errorf("could not find latitude and longitude columns in header row")
+	}
+
+	nodes := z.allNodes()
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read line %d: %s", line, err.Error())
+		}
+		if len(record) <= colLat || len(record) <= colLong {
+			continue
+		}
+		ptName := strings.TrimSpace(record[0])
+		n := nodes[ptName]
+		if n == nil {
+			z.report(baseFile, fmt.Sprintf("L%d", line), SeverityWarning, ptName, "", "unknown node. Skipping")
+			continue
+		}
+		z.setCoordinates(n, baseFile, fmt.Sprintf("L%d", line), record[colLat], record[colLong])
+	}
+	return nil
+}
+
+// geoLink is a troncon between two located nodes
+type geoLink struct {
+	troncon  *node.Troncon
+	from, to *node.Node
+}
+
+// geoItems returns zone located nodes, and troncons linking them
+func (z *Zone) geoItems() (nodes []*node.Node, links []geoLink) {
+	all := z.allNodes()
+	nodes = make([]*node.Node, 0, len(all))
+	for _, n := range all {
+		if n.IsLocated() {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].PtName < nodes[j].PtName
+	})
+	for _, n := range nodes {
+		for _, cn := range n.GetChildren() {
+			if !cn.IsLocated() || cn.TronconIn == nil {
+				continue
+			}
+			links = append(links, geoLink{troncon: cn.TronconIn, from: n, to: cn})
+		}
+	}
+	return
+}
+
+// LocatedNodes returns the number of zone nodes having known coordinates
+func (z *Zone) LocatedNodes() int {
+	nodes, _ := z.geoItems()
+	return len(nodes)
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// WriteGeoJSON writes zone located nodes (Point) and troncons (LineString) as <dir>/<name>.geojson.
+//
+// Features have simplestyle-spec properties (marker-color, stroke) colored by node LocationType and troncon PullingType
+func (z *Zone) WriteGeoJSON(dir, name string) error {
+	nodes, links := z.geoItems()
+	if len(nodes) == 0 {
+		return fmt.Errorf("no located node, nothing to write to GeoJSON")
+	}
+	features := []geoJSONFeature{}
+	for _, n := range nodes {
+		features = append(features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: []float64{n.Long, n.Lat}},
+			Properties: map[string]interface{}{
+				"name":         n.PtName,
+				"ref":          n.Name,
+				"locationType": n.LocationType,
+				"bpeType":      n.BPEType,
+				"address":      n.Address,
+				"distFromPM":   n.DistFromPM,
+				"marker-color": "#" + geoNodeColor(n.LocationType),
+			},
+		})
+	}
+	for _, l := range links {
+		features = append(features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "LineString", Coordinates: [][]float64{{l.from.Long, l.from.Lat}, {l.to.Long, l.to.Lat}}},
+			Properties: map[string]interface{}{
+				"name":              l.troncon.Name,
+				"capa":              l.troncon.CapaString(),
+				"pullingType":       l.troncon.PullingType(),
+				"loveLength":        l.troncon.LoveLength,
+				"undergroundLength": l.troncon.UndergroundLength,
+				"aerialLength":      l.troncon.AerialLength,
+				"facadeLength":      l.troncon.FacadeLength,
+				"from":              l.from.PtName,
+				"to":                l.to.PtName,
+				"stroke":            "#" + geoTronconColor(l.troncon.PullingType()),
+				"stroke-width":      3,
+			},
+		})
+	}
+
+	f, err := os.Create(filepath.Join(dir, name+".geojson"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "\t")
+	return enc.Encode(struct {
+		Type     string           `json:"type"`
+		Name     string           `json:"name"`
+		Features []geoJSONFeature `json:"features"`
+	}{Type: "FeatureCollection", Name: name, Features: features})
+}
+
+type kmlStyle struct {
+	Id        string `xml:"id,attr"`
+	IconColor string `xml:"IconStyle>color,omitempty"`
+	LineColor string `xml:"LineStyle>color,omitempty"`
+	LineWidth int    `xml:"LineStyle>width,omitempty"`
+}
+
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	StyleUrl    string `xml:"styleUrl"`
+	Point       string `xml:"Point>coordinates,omitempty"`
+	LineString  string `xml:"LineString>coordinates,omitempty"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+// kmlColor returns given RRGGBB color as KML aabbggrr one
+func kmlColor(rgb string) string {
+	return "ff" + rgb[4:6] + rgb[2:4] + rgb[0:2]
+}
+
+func kmlCoord(n *node.Node) string {
+	return fmt.Sprintf("%f,%f", n.Long, n.Lat)
+}
+
+// WriteKML writes zone located nodes and troncons as <dir>/<name>.kml, in Noeuds and Tronçons folders,
+// with styles by node LocationType and troncon PullingType
+func (z *Zone) WriteKML(dir, name string) error {
+	nodes, links := z.geoItems()
+	if len(nodes) == 0 {
+		return fmt.Errorf("no located node, nothing to write to KML")
+	}
+
+	styles := []kmlStyle{}
+	for _, locType := range []string{"PM", "BPE", "PBO"} {
+		styles = append(styles, kmlStyle{Id: "node" + locType, IconColor: kmlColor(geoNodeColor(locType))})
+	}
+	for _, pulling := range []string{node.PullingUnderground, node.PullingAerial, node.PullingFacade, ""} {
+		styles = append(styles, kmlStyle{Id: kmlTronconStyle(pulling), LineColor: kmlColor(geoTronconColor(pulling)), LineWidth: 3})
+	}
+
+	nodeFolder := kmlFolder{Name: "Noeuds"}
+	for _, n := range nodes {
+		locType := n.LocationType
+		if locType != "PM" && locType != "PBO" {
+			locType = "BPE"
+		}
+		nodeFolder.Placemarks = append(nodeFolder.Placemarks, kmlPlacemark{
+			Name:        n.PtName,
+			Description: fmt.Sprintf("%s %s - %s (%dm du PM)", n.LocationType, n.BPEType, n.Address, n.DistFromPM),
+			StyleUrl:    "#node" + locType,
+			Point:       kmlCoord(n),
+		})
+	}
+	tronconFolder := kmlFolder{Name: "Tronçons"}
+	for _, l := range links {
+		tronconFolder.Placemarks = append(tronconFolder.Placemarks, kmlPlacemark{
+			Name:        l.troncon.Name,
+			Description: fmt.Sprintf("%s %s : %s -> %s", l.troncon.CapaString(), l.troncon.PullingType(), l.from.PtName, l.to.PtName),
+			StyleUrl:    "#" + kmlTronconStyle(l.troncon.PullingType()),
+			LineString:  kmlCoord(l.from) + " " + kmlCoord(l.to),
+		})
+	}
+
+	doc := struct {
+		XMLName xml.Name    `xml:"kml"`
+		Xmlns   string      `xml:"xmlns,attr"`
+		Name    string      `xml:"Document>name"`
+		Styles  []kmlStyle  `xml:"Document>Style"`
+		Folders []kmlFolder `xml:"Document>Folder"`
+	}{
+		Xmlns:   "http://www.opengis.net/kml/2.2",
+		Name:    name,
+		Styles:  styles,
+		Folders: []kmlFolder{nodeFolder, tronconFolder},
+	}
+
+	f, err := os.Create(filepath.Join(dir, name+".kml"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "\t")
+	return enc.Encode(doc)
+}
+
+func kmlTronconStyle(pullingType string) string {
+	switch pullingType {
+	case node.PullingUnderground:
+		return "tronconSouterrain"
+	case node.PullingAerial:
+		return "tronconAerien"
+	case node.PullingFacade:
+		return "tronconFacade"
+	}
+	return "tronconInconnu"
+}
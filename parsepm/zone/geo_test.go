@@ -0,0 +1,57 @@
+package zone
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestZone_WriteGeoJSON(t *testing.T) {
+	z := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+	z.Nodes["PT 1"].TronconIn.AerialLength = 120
+
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "coords.csv")
+	csv := "PT;Latitude;Longitude\nPM1;48,6911;6,1825\nPT 1;48,6925;6,1850\nPT 2;48.7;6.2\nPT 3;123;6\n"
+	if err := ioutil.WriteFile(csvFile, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.ParseCoordinatesCSV(csvFile); err != nil {
+		t.Fatalf("ParseCoordinatesCSV returned unexpected error: %s", err.Error())
+	}
+	if len(z.Diagnostics) != 2 {
+		t.Errorf("ParseCoordinatesCSV reported %d diagnostics instead of 2 (unknown PT 2 and PT 3):\n%s", len(z.Diagnostics), z.Diagnostics.Error())
+	}
+	if pt := z.Nodes["PT 1"]; pt.Lat != 48.6925 || pt.Long != 6.185 {
+		t.Errorf("unexpected PT 1 coordinates %f, %f", pt.Lat, pt.Long)
+	}
+
+	if err := z.WriteGeoJSON(dir, "test"); err != nil {
+		t.Fatalf("WriteGeoJSON returned unexpected error: %s", err.Error())
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "test.geojson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := struct {
+		Features []struct {
+			Geometry   struct{ Type string }
+			Properties map[string]interface{}
+		}
+	}{}
+	if err := json.Unmarshal(content, &fc); err != nil {
+		t.Fatalf("could not unmarshal GeoJSON file: %s", err.Error())
+	}
+	if len(fc.Features) != 3 {
+		t.Fatalf("GeoJSON file has %d features instead of 3 (2 nodes, 1 troncon)", len(fc.Features))
+	}
+	if line := fc.Features[2]; line.Geometry.Type != "LineString" || line.Properties["pullingType"] != "Aérien" {
+		t.Errorf("unexpected troncon feature %v", line)
+	}
+
+	if err := z.WriteKML(dir, "test"); err != nil {
+		t.Fatalf("WriteKML returned unexpected error: %s", err.Error())
+	}
+}
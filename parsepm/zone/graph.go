@@ -0,0 +1,209 @@
+package zone
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+)
+
+// graphNode is a zone node positioned in the topology graph
+type graphNode struct {
+	node  *node.Node
+	depth int
+	y     float64 // vertical position, in leaf slots
+	label []string
+}
+
+// graphEdge links a node to one of its children, through child TronconIn
+type graphEdge struct {
+	from, to *graphNode
+	label    []string
+}
+
+// graph returns zone topology nodes (from SRO or root nodes down to PBOs, in tree order) and edges
+func (z *Zone) graph() (nodes []*graphNode, edges []graphEdge) {
+	visited := map[*node.Node]bool{}
+	leaves := 0
+	var visit func(n *node.Node, depth int) *graphNode
+	visit = func(n *node.Node, depth int) *graphNode {
+		visited[n] = true
+		gn := &graphNode{node: n, depth: depth, label: graphNodeLabel(n)}
+		nodes = append(nodes, gn)
+		children := []*graphNode{}
+		for _, cn := range n.GetChildren() {
+			if visited[cn] {
+				continue
+			}
+			gcn := visit(cn, depth+1)
+			children = append(children, gcn)
+			edges = append(edges, graphEdge{from: gn, to: gcn, label: graphEdgeLabel(n, cn)})
+		}
+		if len(children) == 0 {
+			gn.y = float64(leaves)
+			leaves++
+			return gn
+		}
+		gn.y = (children[0].y + children[len(children)-1].y) / 2
+		return gn
+	}
+	for _, root := range z.roots() {
+		if !visited[root] {
+			visit(root, 0)
+		}
+	}
+	return
+}
+
+// graphNodeLabel returns given node label lines : PT name, location and box types, and number of Attente
+func graphNodeLabel(n *node.Node) []string {
+	res := []string{n.PtName}
+	if types := strings.TrimSpace(n.LocationType + " " + n.BPEType); types != "" && n.BPEType != n.LocationType {
+		res = append(res, types)
+	} else if n.LocationType != "" {
+		res = append(res, n.LocationType)
+	}
+	if nb := n.Operation[opvocab.OpWaiting]; nb > 0 {
+		res = append(res, fmt.Sprintf("%s %d", opvocab.OpWaiting, nb))
+	}
+	return res
+}
+
+// graphEdgeLabel returns label lines of the edge from n to its child cn : troncon name and capacity, and n operations (Epissure, Passage) on troncon
+func graphEdgeLabel(n, cn *node.Node) []string {
+	if cn.TronconIn == nil {
+		return nil
+	}
+	res := []string{cn.TronconIn.Name, cn.TronconIn.CapaString()}
+	for _, ope := range n.Operations() {
+		if strings.HasSuffix(ope, "->"+cn.TronconIn.Name) {
+			res = append(res, fmt.Sprintf("%s %d", strings.TrimSuffix(ope, "->"+cn.TronconIn.Name), n.Operation[ope]))
+		}
+	}
+	return res
+}
+
+// graphColor returns given node fill color (Racco sheet one) as #RRGGBB
+func graphColor(n *node.Node) string {
+	return "#" + n.RaccoColor()[2:]
+}
+
+// WriteDOT writes zone topology as Graphviz DOT graph in <dir>/<name>.dot
+func (z *Zone) WriteDOT(dir, name string) error {
+	return z.writeGraphFile(filepath.Join(dir, name+".dot"), func(w io.Writer) error { return z.writeDOT(w, name) })
+}
+
+// WriteSVG writes zone topology as standalone SVG drawing in <dir>/<name>.svg
+func (z *Zone) WriteSVG(dir, name string) error {
+	return z.writeGraphFile(filepath.Join(dir, name+".svg"), func(w io.Writer) error { return z.writeSVG(w, name) })
+}
+
+func (z *Zone) writeGraphFile(file string, write func(w io.Writer) error) error {
+	if len(z.Nodes) == 0 {
+		return fmt.Errorf("zone is empty, nothing to draw")
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func dotString(lines []string) string {
+	return `"` + strings.Replace(strings.Join(lines, `\n`), `"`, `\"`, -1) + `"`
+}
+
+func (z *Zone) writeDOT(w io.Writer, name string) error {
+	nodes, edges := z.graph()
+	fmt.Fprintf(w, "digraph %s {\n", dotString([]string{name}))
+	fmt.Fprintf(w, "\trankdir=LR;\n")
+	fmt.Fprintf(w, "\tnode [shape=box, style=\"rounded,filled\", fontname=\"Calibri\", fontsize=10];\n")
+	fmt.Fprintf(w, "\tedge [fontname=\"Calibri\", fontsize=9];\n")
+	for _, gn := range nodes {
+		fmt.Fprintf(w, "\t%s [label=%s, fillcolor=\"%s\"];\n", dotString([]string{gn.node.PtName}), dotString(gn.label), graphColor(gn.node))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", dotString([]string{e.from.node.PtName}), dotString([]string{e.to.node.PtName}), dotString(e.label))
+	}
+	_, err := fmt.Fprintf(w, "}\n")
+	return err
+}
+
+const (
+	svgMargin     float64 = 20
+	svgBoxWidth   float64 = 160
+	svgEdgeLength float64 = 160
+	svgLineHeight float64 = 14
+	svgSlotHeight float64 = 80
+)
+
+func svgBoxHeight(gn *graphNode) float64 {
+	return float64(len(gn.label))*svgLineHeight + 10
+}
+
+// svgPos returns the top left corner of given node box
+func svgPos(gn *graphNode) (x, y float64) {
+	x = svgMargin + float64(gn.depth)*(svgBoxWidth+svgEdgeLength)
+	y = svgMargin + gn.y*svgSlotHeight + (svgSlotHeight-svgBoxHeight(gn))/2
+	return
+}
+
+func svgText(w io.Writer, x, y float64, anchor, style string, lines []string) {
+	fmt.Fprintf(w, "\t<text x=\"%.1f\" y=\"%.1f\" text-anchor=\"%s\" %s>", x, y, anchor, style)
+	for i, line := range lines {
+		dy := svgLineHeight
+		if i == 0 {
+			dy = 0
+		}
+		fmt.Fprintf(w, "<tspan x=\"%.1f\" dy=\"%.1f\">%s</tspan>", x, dy, html.EscapeString(line))
+	}
+	fmt.Fprintf(w, "</text>\n")
+}
+
+// writeSVG draws the zone topology as a left to right tree (no Graphviz layout engine required)
+func (z *Zone) writeSVG(w io.Writer, name string) error {
+	nodes, edges := z.graph()
+	maxDepth, maxY := 0, 0.0
+	for _, gn := range nodes {
+		if gn.depth > maxDepth {
+			maxDepth = gn.depth
+		}
+		if gn.y > maxY {
+			maxY = gn.y
+		}
+	}
+	width := 2*svgMargin + float64(maxDepth+1)*svgBoxWidth + float64(maxDepth)*svgEdgeLength
+	height := 2*svgMargin + (maxY+1)*svgSlotHeight
+
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\" font-family=\"Calibri, Arial, sans-serif\">\n", width, height, width, height)
+	fmt.Fprintf(w, "\t<title>%s</title>\n", html.EscapeString(name))
+
+	for _, e := range edges {
+		fx, fy := svgPos(e.from)
+		tx, ty := svgPos(e.to)
+		x1, y1 := fx+svgBoxWidth, fy+svgBoxHeight(e.from)/2
+		x2, y2 := tx, ty+svgBoxHeight(e.to)/2
+		fmt.Fprintf(w, "\t<path d=\"M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f\" fill=\"none\" stroke=\"#6f6f6f\"/>\n", x1, y1, (x1+x2)/2, y1, (x1+x2)/2, y2, x2, y2)
+		svgText(w, (x1+x2)/2, y2-float64(len(e.label))*svgLineHeight/2, "middle", `font-size="9" fill="#404040"`, e.label)
+	}
+	for _, gn := range nodes {
+		x, y := svgPos(gn)
+		fmt.Fprintf(w, "\t<rect x=\"%.1f\" y=\"%.1f\" width=\"%.0f\" height=\"%.0f\" rx=\"6\" fill=\"%s\" stroke=\"#404040\"/>\n", x, y, svgBoxWidth, svgBoxHeight(gn), graphColor(gn.node))
+		svgText(w, x+svgBoxWidth/2, y+svgLineHeight, "middle", `font-size="10"`, gn.label)
+	}
+	_, err := fmt.Fprintf(w, "</svg>\n")
+	return err
+}
@@ -0,0 +1,36 @@
+package zone
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestZone_WriteGraph(t *testing.T) {
+	z := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+
+	dot := &bytes.Buffer{}
+	if err := z.writeDOT(dot, "test"); err != nil {
+		t.Fatalf("writeDOT returned unexpected error: %s", err.Error())
+	}
+	if edge := `"PM1" -> "PT 1" [label="CABLE 1\n`; !strings.Contains(dot.String(), edge) {
+		t.Errorf("DOT graph does not contain edge '%s':\n%s", edge, dot.String())
+	}
+
+	svg := &bytes.Buffer{}
+	if err := z.writeSVG(svg, "test"); err != nil {
+		t.Fatalf("writeSVG returned unexpected error: %s", err.Error())
+	}
+	doc := struct {
+		Rects []struct{} `xml:"rect"`
+		Paths []struct{} `xml:"path"`
+	}{}
+	if err := xml.Unmarshal(svg.Bytes(), &doc); err != nil {
+		t.Fatalf("SVG drawing is not valid XML: %s", err.Error())
+	}
+	if len(doc.Rects) != 2 || len(doc.Paths) != 1 {
+		t.Errorf("SVG drawing has %d nodes and %d edges instead of 2 and 1", len(doc.Rects), len(doc.Paths))
+	}
+}
@@ -0,0 +1,203 @@
+package zone
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	nbFieldCols int = 5 // Statut, Acteur(s), N° Déplacement, Début, Fin
+
+	sheetRevision string = "Révision"
+	revisionNew   string = "Nouveau"
+	revisionGone  string = "Supprimé"
+)
+
+// suiviRowKey returns the key identifying a suivi sheet row (empty string if row holds no field columns).
+//
+// Tirage : cable (first troncon name) or troncon rows, Racco : node (TOTAL) or node operation rows, Mesures : node rows
+type suiviRowKey func(sh *xlsx.Sheet, row int, current *string) string
+
+var suiviSheets = []struct {
+	name      string
+	colStatus int
+	key       suiviRowKey
+}{
+	{sheetTirage, colTirageStatus, func(sh *xlsx.Sheet, row int, current *string) string {
+		trName := sh.Cell(row, colTirageTronconName).Value
+		switch {
+		case trName == "":
+			return ""
+		case sh.Cell(row, colTirageCableType).Value != "":
+			return "Cable " + trName
+		}
+		return "Troncon " + trName
+	}},
+	{sheetRacco, colRaccoStatus, func(sh *xlsx.Sheet, row int, current *string) string {
+		ope := sh.Cell(row, colRaccoOpe).Value
+		switch ope {
+		case "":
+			return ""
+		case "TOTAL":
+			*current = sh.Cell(row, colRaccoPtName).Value
+			return *current
+		}
+		return *current + " " + ope
+	}},
+	{sheetMesures, colMesuresStatus, func(sh *xlsx.Sheet, row int, current *string) string {
+		if sh.Cell(row, colMesuresNbFiber).Value == "" {
+			return ""
+		}
+		return sh.Cell(row, colMesuresPtName).Value
+	}},
+}
+
+// MergeXLS regenerates <dir>/<name>_suivi.xlsx from current zone design, carrying over field columns (Statut, Acteur(s), N° Déplacement, Début, Fin)
+// from the already existing workbook for rows whose PT, troncon or operation still exist.
+//
+// Appeared rows are flagged as new, and disappeared ones are listed in a Révision sheet (and returned as Diagnostics).
+// The previous workbook is kept as <name>_suivi_<date>.xlsx. If no previous workbook exists, MergeXLS behaves as WriteXLS
+func (z *Zone) MergeXLS(dir, name string) (Diagnostics, error) {
+	file := suiviFile(dir, name)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil, z.WriteXLS(dir, name)
+	}
+	prev, err := xlsx.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	xls, err := z.buildXLS()
+	if err != nil {
+		return nil, err
+	}
+
+	diags, err := mergeSuiviXLS(xls, prev, filepath.Base(file))
+	if err != nil {
+		return nil, err
+	}
+
+	backup := filepath.Join(dir, fmt.Sprintf("%s_suivi_%s.xlsx", name, time.Now().Format("2006-01-02_150405")))
+	err = os.Rename(file, backup)
+	if err != nil {
+		return nil, fmt.Errorf("could not backup previous suivi file: %s", err.Error())
+	}
+	return diags, writeXLSFile(xls, file)
+}
+
+// mergeSuiviXLS copies field columns from prev workbook to xls one, and reports appeared and disappeared rows
+func mergeSuiviXLS(xls, prev *xlsx.File, source string) (diags Diagnostics, err error) {
+	var revSheet *xlsx.Sheet
+	for _, ss := range suiviSheets {
+		sh, prevSh := xls.Sheet[ss.name], prev.Sheet[ss.name]
+		if sh == nil || prevSh == nil {
+			continue
+		}
+
+		prevRows := map[string]int{}
+		current := ""
+		for row := 1; row < prevSh.MaxRow; row++ {
+			if key := ss.key(prevSh, row, &current); key != "" {
+				prevRows[key] = row
+			}
+		}
+
+		// revision column is added after generated sheet last column
+		colRevision := sh.MaxCol
+		sh.Cell(0, colRevision).SetString(sheetRevision)
+		current = ""
+		for row := 1; row < sh.MaxRow; row++ {
+			key := ss.key(sh, row, &current)
+			if key == "" {
+				continue
+			}
+			prevRow, found := prevRows[key]
+			if !found {
+				sh.Cell(row, colRevision).SetString(revisionNew)
+				diags = append(diags, Diagnostic{Source: source, Cell: ss.name + "!" + xlsx.GetCellIDStringFromCoords(0, row), Severity: SeverityWarning, Msg: fmt.Sprintf("new row '%s'", key)})
+				continue
+			}
+			delete(prevRows, key)
+			for col := ss.colStatus; col < ss.colStatus+nbFieldCols; col++ {
+				copyCell(sh.Cell(row, col), prevSh.Cell(prevRow, col))
+			}
+		}
+
+		// remaining previous rows have disappeared (listed below a copy of previous sheet header)
+		revHeader := false
+		current = ""
+		for row := 1; row < prevSh.MaxRow; row++ {
+			key := ss.key(prevSh, row, &current)
+			if _, gone := prevRows[key]; !gone || key == "" {
+				continue
+			}
+			sev := SeverityWarning
+			msg := fmt.Sprintf("row '%s' has disappeared", key)
+			if prevSh.Cell(row, ss.colStatus).Value != "" {
+				sev = SeverityError
+				msg += fmt.Sprintf(" (status was '%s')", prevSh.Cell(row, ss.colStatus).Value)
+			}
+			diags = append(diags, Diagnostic{Source: source, Cell: ss.name + "!" + xlsx.GetCellIDStringFromCoords(0, row), Severity: sev, Msg: msg})
+			if revSheet == nil {
+				revSheet, err = addRevisionSheet(xls)
+				if err != nil {
+					return
+				}
+			}
+			if !revHeader {
+				addRevisionHeader(revSheet, prevSh)
+				revHeader = true
+			}
+			r := revSheet.AddRow()
+			r.AddCell().SetString(ss.name)
+			r.AddCell().SetString(revisionGone)
+			for col := 0; col < prevSh.MaxCol; col++ {
+				copyCell(r.AddCell(), prevSh.Cell(row, col))
+			}
+		}
+	}
+	return
+}
+
+func addRevisionSheet(xls *xlsx.File) (*xlsx.Sheet, error) {
+	sheet, err := xls.AddSheet(sheetRevision)
+	if err != nil {
+		return nil, err
+	}
+	sheet.Col(0).Width = 12
+	sheet.Col(1).Width = 12
+	return sheet, nil
+}
+
+// addRevisionHeader adds to given revision sheet the header row of the rows disappeared from given previous sheet : sheet name and change
+// columns, followed by previous sheet header
+func addRevisionHeader(revSheet, prevSh *xlsx.Sheet) {
+	r := revSheet.AddRow()
+	r.AddCell().SetString("Onglet")
+	r.AddCell().SetString("Changement")
+	for col := 0; col < prevSh.MaxCol; col++ {
+		copyCell(r.AddCell(), prevSh.Cell(0, col))
+	}
+}
+
+// copyCell sets dst value (and number format) with src one
+func copyCell(dst, src *xlsx.Cell) {
+	switch src.Type() {
+	case xlsx.CellTypeNumeric:
+		if f, err := src.Float(); err == nil {
+			dst.SetFloatWithFormat(f, src.NumFmt)
+			return
+		}
+	case xlsx.CellTypeBool:
+		dst.SetBool(src.Bool())
+		return
+	}
+	if strings.TrimSpace(src.Value) == "" {
+		return
+	}
+	dst.SetString(src.Value)
+}
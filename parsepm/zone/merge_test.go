@@ -0,0 +1,74 @@
+package zone
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tealeg/xlsx"
+)
+
+func setRaccoStatus(t *testing.T, file string, status map[string]string) {
+	xf, err := xlsx.OpenFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	racco := xf.Sheet[sheetRacco]
+	for row := 1; row < racco.MaxRow; row++ {
+		if racco.Cell(row, colRaccoOpe).Value != "TOTAL" {
+			continue
+		}
+		if st, found := status[racco.Cell(row, colRaccoPtName).Value]; found {
+			racco.Cell(row, colRaccoStatus).SetString(st)
+		}
+	}
+	if err := xf.Save(file); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZone_MergeXLS(t *testing.T) {
+	dir := t.TempDir()
+	z := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+	if err := z.WriteXLS(dir, "test"); err != nil {
+		t.Fatalf("WriteXLS returned unexpected: %s", err.Error())
+	}
+	setRaccoStatus(t, suiviFile(dir, "test"), map[string]string{"PM1": "en cours", "PT 1": "OK"})
+
+	// new design version : PT 1 is replaced by PT 2
+	sheet := newTestRopSheet(t, "SERVICE", "120")
+	sheet.Cell(1, DefaultRopLayout().ColFirstChild+DefaultRopLayout().BlockPtName).SetString("PT 2")
+	nz := New()
+	NewRopParser(sheet, nz).ParseRop()
+	diags, err := nz.MergeXLS(dir, "test")
+	if err != nil {
+		t.Fatalf("MergeXLS returned unexpected: %s", err.Error())
+	}
+	if len(diags.Filter(SeverityError)) != 1 {
+		t.Errorf("disappeared PT 1 with status should be reported as error:\n%s", diags.Error())
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "test_suivi_*.xlsx"))
+	if len(backups) != 1 {
+		t.Errorf("previous suivi file should have been backed up")
+	}
+	if _, err := nz.ParseSuiviXLS(suiviFile(dir, "test")); err != nil {
+		t.Fatal(err)
+	}
+	if nz.Sro.JunctionStatus == nil || nz.Sro.JunctionStatus.Label != "en cours" {
+		t.Errorf("PM1 status should have been carried over")
+	}
+	if nz.Nodes["PT 2"].JunctionStatus != nil {
+		t.Errorf("PT 2 should not have any status")
+	}
+	xf, err := xlsx.OpenFile(suiviFile(dir, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xf.Sheet[sheetRevision] == nil || xf.Sheet[sheetRevision].Cell(1, 2).Value != "PT 1" {
+		t.Errorf("disappeared PT 1 should be listed in %s sheet", sheetRevision)
+	}
+	if rev := xf.Sheet[sheetRevision]; rev != nil && (rev.Cell(0, 1).Value != "Changement" || rev.Cell(0, 2).Value != xf.Sheet[sheetRacco].Cell(0, 0).Value) {
+		t.Errorf("disappeared rows should be listed below previous %s sheet header", sheetRacco)
+	}
+}
@@ -2,10 +2,10 @@ package zone
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
 	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
 	"github.com/tealeg/xlsx"
 )
@@ -22,37 +22,38 @@ func (p Pos) Right(offset int) Pos {
 type RopParser struct {
 	sheet          *xlsx.Sheet
 	zone           *Zone
+	layout         RopLayout
 	pos            Pos
 	serviceCol     int
-	CountReserveOR bool
+	CountReserveOR bool            // if true, reserve routes attentes are counted as client ones (otherwise, they are tallied in node Stock)
+	Strict         bool            // if true, parsing stops on first fatal diagnostic
+	unknownOps     map[string]bool // unknown operation labels already reported, per node (shared by child parsers)
 }
 
-const (
-	acolPmName     int = 0
-	acolDrawer     int = 3
-	acolDrawerLine int = 4
-	acolDrawerCol  int = 5
-	acolFirstChild int = 6
-	colTubulure    int = 0
-	colCableIn     int = 2
-	colName        int = 4
-	colPtName      int = 5
-	colDistFromPM  int = 6
-	colOpe         int = 7
-	colNextBlock   int = 8
-)
-
 func NewRopParser(sh *xlsx.Sheet, zone *Zone) *RopParser {
 	rp := &RopParser{
-		sheet: sh,
-		zone:  zone,
+		sheet:      sh,
+		zone:       zone,
+		layout:     zone.RopLayout,
+		unknownOps: map[string]bool{},
 	}
 	return rp
 }
 
-func (rp *RopParser) debug(msg string) {
-	log.Fatalf("pos %s : %s", xlsx.GetCellIDStringFromCoords(rp.pos.col, rp.pos.row), msg)
-	//log.Printf("pos %s : %s", xlsx.GetCellIDStringFromCoords(rp.pos.col, rp.pos.row), msg)
+// diag returns a Diagnostic positioned on current RopParser pos, shifted by colOffset
+func (rp *RopParser) diag(colOffset int, sev Severity, ptName, troncon, msg string) Diagnostic {
+	return Diagnostic{
+		Cell:     xlsx.GetCellIDStringFromCoords(rp.pos.col+colOffset, rp.pos.row),
+		Severity: sev,
+		PtName:   ptName,
+		Troncon:  troncon,
+		Msg:      msg,
+	}
+}
+
+// mustStop returns true if parsing has to be stopped because of given diagnostics
+func (rp *RopParser) mustStop(diags Diagnostics) bool {
+	return rp.Strict && diags.HasFatal()
 }
 
 func (rp *RopParser) CloneRopParser() *RopParser {
@@ -62,7 +63,7 @@ func (rp *RopParser) CloneRopParser() *RopParser {
 
 func (rp *RopParser) GetChildRopParser() *RopParser {
 	nrp := *rp
-	nrp.pos = rp.pos.Right(colNextBlock)
+	nrp.pos = rp.pos.Right(rp.layout.BlockNext)
 	return &nrp
 }
 
@@ -83,59 +84,79 @@ func (rp *RopParser) GetValue(colOffset int) string {
 }
 
 func (rp *RopParser) FindServiceColumn() bool {
-	colNum := acolFirstChild
-	for rp.GetPosValue(0, colNum) == "T" {
-		colNum += colNextBlock
+	colNum := rp.layout.ColFirstChild
+	for rp.GetPosValue(0, colNum) == rp.layout.BlockMarker {
+		colNum += rp.layout.BlockNext
 	}
-	rp.serviceCol = colNum + 2
-	return rp.GetPosValue(0, rp.serviceCol) == "SERVICE"
+	rp.serviceCol = colNum + rp.layout.ServiceColOffset
+	return rp.GetPosValue(0, rp.serviceCol) == rp.layout.ServiceHeader
 }
 
 func (rp *RopParser) IsCurrentRouteForClient() bool {
-	serviceValue := strings.ToLower(strings.Trim(rp.GetPosValue(rp.pos.row, rp.serviceCol), " "))
+	serviceValue := rp.GetPosValue(rp.pos.row, rp.serviceCol)
 	// return forClient || forReserve
-	forClient := strings.HasPrefix(serviceValue, "client ftth")
+	forClient := rp.layout.IsClientService(serviceValue)
 	if rp.CountReserveOR && !forClient {
-		return strings.HasPrefix(serviceValue, "reserve")
+		return rp.layout.IsReserveService(serviceValue)
 	}
 	return forClient
 }
 
+// GetOperation returns the canonical type of current block operation (empty if none or unknown).
+//
+// Operation labels not found in zone vocabulary are reported once per node
+func (rp *RopParser) GetOperation(ptName string) (opType string, diags Diagnostics) {
+	label := rp.GetValue(rp.layout.BlockOpe)
+	opType, known := rp.zone.Vocabulary.Canonical(opvocab.SourceROP, label)
+	if !known && !rp.unknownOps[ptName+"\t"+label] {
+		rp.unknownOps[ptName+"\t"+label] = true
+		diags = append(diags, rp.diag(rp.layout.BlockOpe, SeverityWarning, ptName, "", fmt.Sprintf("unknown operation '%s' : not counted", label)))
+	}
+	return
+}
+
+// IsCurrentRouteForReserve returns true if current route is a reserve one
+func (rp *RopParser) IsCurrentRouteForReserve() bool {
+	return rp.layout.IsReserveService(rp.GetPosValue(rp.pos.row, rp.serviceCol))
+}
+
 // GetParentPtName return parent PT (or PM) name
 func (rp *RopParser) GetParentPtName() string {
-	col := rp.pos.col + colPtName - colNextBlock
-	if col < acolFirstChild {
-		col = acolPmName
+	col := rp.pos.col + rp.layout.BlockPtName - rp.layout.BlockNext
+	if col < rp.layout.ColFirstChild {
+		col = rp.layout.ColPmName
 	}
 	return rp.sheet.Cell(rp.pos.row, col).Value
 }
 
 // ChildExists returns true if Child block exists
 func (rp *RopParser) ChildExists() bool {
-	if rp.GetPosValue(0, rp.pos.col+colNextBlock) == "T" && rp.GetValue(colNextBlock) != "" {
+	if rp.GetPosValue(0, rp.pos.col+rp.layout.BlockNext) == rp.layout.BlockMarker && rp.GetValue(rp.layout.BlockNext) != "" {
 		return true
 	}
 	return false
 }
 
 // SetNodeInfo sets node Name and check TronconIn consistency (creates It if not already defined)
-func (rp *RopParser) SetNodeInfo(n *node.Node) {
+//
+// returned diagnostics list all found inconsistencies
+func (rp *RopParser) SetNodeInfo(n *node.Node) (diags Diagnostics) {
 	// set DistFromPM
-	distString := rp.GetValue(colDistFromPM)
+	distString := rp.GetValue(rp.layout.BlockDistFromPM)
 	dist, err := strconv.ParseInt(distString, 10, 64)
 	if err != nil {
-		rp.debug(fmt.Sprintf("Parse: could not get distance from '%s'", distString))
+		diags = append(diags, rp.diag(rp.layout.BlockDistFromPM, SeverityError, n.PtName, "", fmt.Sprintf("could not get distance from '%s'", distString)))
 	}
 	n.DistFromPM = int(dist)
 
-	n.Name = rp.GetValue(colName)
+	n.Name = rp.GetValue(rp.layout.BlockName)
 	// check cable In consistency
-	cableInName := rp.GetValue(colCableIn)
+	cableInName := rp.GetValue(rp.layout.BlockCableIn)
 
 	// if TronconIn already defined, check its consistency
 	if n.TronconIn != nil && cableInName != n.TronconIn.Name {
 		if strings.ReplaceAll(cableInName, " ", "") != strings.ReplaceAll(n.TronconIn.Name, " ", "") {
-			rp.debug(fmt.Sprintf("SetNodeInfo: not matching cable In name '%s' ('%s' expected) for node '%s'", cableInName, n.TronconIn.Name, n.PtName))
+			diags = append(diags, rp.diag(rp.layout.BlockCableIn, SeverityError, n.PtName, cableInName, fmt.Sprintf("not matching cable In name '%s' ('%s' expected)", cableInName, n.TronconIn.Name)))
 		}
 		cableInName = n.TronconIn.Name
 	}
@@ -144,7 +165,7 @@ func (rp *RopParser) SetNodeInfo(n *node.Node) {
 		trIn := rp.zone.Troncons[cableInName]
 		if trIn == nil {
 			if !rp.zone.CreateNodeFromRop {
-				rp.debug(fmt.Sprintf("SetNodeInfo: could not get troncon from cable '%s'", cableInName))
+				diags = append(diags, rp.diag(rp.layout.BlockCableIn, SeverityError, n.PtName, cableInName, "could not get troncon from cable In name"))
 			}
 			trIn = node.NewTroncon(cableInName)
 			rp.zone.Troncons.Add(trIn)
@@ -152,39 +173,54 @@ func (rp *RopParser) SetNodeInfo(n *node.Node) {
 			parentNodeName := rp.GetParentPtName()
 			parentNode, found := rp.zone.Nodes[parentNodeName]
 			if !found {
-				rp.debug(fmt.Sprintf("SetNodeInfo: could not get parentNode '%s'", parentNodeName))
+				diags = append(diags, rp.diag(rp.layout.BlockCableIn, SeverityFatal, n.PtName, cableInName, fmt.Sprintf("could not get parent node '%s'", parentNodeName)))
 			}
 			trIn.NodeSource = parentNode
 		}
 		if trIn.NodeDest == nil {
 			trIn.NodeDest = n
 			n.TronconIn = trIn
-			trIn.NodeSource.AddChild(n)
+			if trIn.NodeSource != nil {
+				trIn.NodeSource.AddChild(n)
+			}
 		}
 		if trIn.NodeDest != nil && trIn.NodeDest.PtName != n.PtName {
-			rp.debug(fmt.Sprintf("SetNodeInfo: troncon '%s' already has a destination node '%s' instead of '%s'", cableInName, trIn.NodeDest.PtName, n.PtName))
+			diags = append(diags, rp.diag(rp.layout.BlockCableIn, SeverityFatal, n.PtName, cableInName, fmt.Sprintf("troncon already has a destination node '%s' : block skipped", trIn.NodeDest.PtName)))
 		}
 	}
+	return
 }
 
-func (rp *RopParser) ParseRop() {
+// ParseRop parses the whole ROP sheet to populate zone Node tree, and returns all found diagnostics
+//
+// in Strict mode, parsing stops on first fatal diagnostic
+func (rp *RopParser) ParseRop() (diags Diagnostics) {
 	// check for Service column
 	if !rp.FindServiceColumn() {
 		rp.pos = Pos{0, rp.serviceCol}
-		rp.debug("could not find service column")
+		diags = append(diags, rp.diag(0, SeverityFatal, "", "", "could not find service column"))
+		if rp.mustStop(diags) {
+			return
+		}
 	}
-	rp.pos = Pos{1, acolFirstChild}
+	rp.pos = Pos{1, rp.layout.ColFirstChild}
 	// Init root PM Node
-	rp.zone.Sro.PtName = rp.GetPosValue(rp.pos.row, acolPmName)
+	rp.zone.Sro.PtName = rp.GetPosValue(rp.pos.row, rp.layout.ColPmName)
 	rp.zone.Sro.LocationType = "PM"
 	rp.zone.Nodes.Add(rp.zone.Sro)
 
 	// Start Parsing
 	done := false
 	for !done {
-		if rp.GetValue(colTubulure) != "" {
-			topnode := rp.Parse()
-			rp.zone.Sro.AddChild(topnode)
+		if rp.GetValue(rp.layout.BlockTubulure) != "" {
+			topnode, nDiags := rp.Parse()
+			diags = append(diags, nDiags...)
+			if rp.mustStop(diags) {
+				return
+			}
+			if topnode.TronconIn != nil {
+				rp.zone.Sro.AddChild(topnode)
+			}
 			continue
 		}
 		if rp.GetValue(-1) == "" {
@@ -196,34 +232,38 @@ func (rp *RopParser) ParseRop() {
 	rp.zone.Sro.SetOperationFromChildren()
 	rp.zone.Sro.SetSplicePTs()
 	rp.zone.DetectCables(rp.zone.Sro)
+	rp.zone.ResolvePaths()
+	return
 }
 
 // Parse returns current block Node (populated with all its defined children) and move RopParser pos to the next child within same level
-func (rp *RopParser) Parse() *node.Node {
-	ptName := rp.GetValue(colPtName)
-	currentNode := rp.zone.Nodes[ptName]
+//
+// returned diagnostics list all inconsistencies found in current block (and its children blocks)
+func (rp *RopParser) Parse() (currentNode *node.Node, diags Diagnostics) {
+	ptName := rp.GetValue(rp.layout.BlockPtName)
+	currentNode = rp.zone.Nodes[ptName]
 	if currentNode == nil {
 		// the Node is not already defined after processing BPE Directory
 		if !strings.Contains(ptName, "_PM") {
 			if !rp.zone.CreateNodeFromRop {
-				// unknown Pt is not a PM ... exit with debug
-				rp.debug(fmt.Sprintf("Parse: could not get node from ptname '%s'", ptName))
+				// unknown Pt is not a PM ... report it and create it anyway
+				diags = append(diags, rp.diag(rp.layout.BlockPtName, SeverityError, ptName, "", "unknown node (not defined in BPE directory)"))
 			}
 			// Unknown Node, creating it from RopFile data
 			fmt.Printf("\tCreate node '%s' from Rop File\n", ptName)
 			currentNode = node.NewNode()
 			currentNode.PtName = ptName
-			currentNode.Name = rp.GetValue(colName)
+			currentNode.Name = rp.GetValue(rp.layout.BlockName)
 			rp.zone.Nodes.Add(currentNode)
 		} else {
 			// unknown Pt is a PM, let's create it
 			currentNode = node.NewPMNode(nil)
 			currentNode.PtName = ptName
-			currentNode.Name = rp.GetValue(colName)
+			currentNode.Name = rp.GetValue(rp.layout.BlockName)
 			rp.zone.Nodes.Add(currentNode)
 
 			// check if cableIn is already defined (false when PM directly behind NRO => creates it)
-			cableInName := rp.GetValue(colCableIn)
+			cableInName := rp.GetValue(rp.layout.BlockCableIn)
 			trIn := rp.zone.Troncons[cableInName]
 			if trIn == nil {
 				newTr := node.NewTroncon(cableInName)
@@ -232,62 +272,87 @@ func (rp *RopParser) Parse() *node.Node {
 			}
 		}
 	}
-	rp.SetNodeInfo(currentNode)
+	diags = append(diags, rp.SetNodeInfo(currentNode)...)
+	if rp.mustStop(diags) {
+		return
+	}
+	if currentNode.TronconIn == nil {
+		// cable In is claimed by another node : current block can not be attached to the tree
+		rp.skipBlock(currentNode)
+		return
+	}
 	if rp.zone.CreateNodeFromRop && !rp.zone.DefineNodeOperation[ptName] {
 		// Operation are to be defined from Rop data
 		// reset Operation
 		currentNode.Operation = make(map[string]int)
+		currentNode.Stock = node.FiberStock{}
 		// mark currentNode operation as reseted
 		rp.zone.DefineNodeOperation[ptName] = true
 	}
 	inNode := true
 	for inNode {
+		opType, oDiags := rp.GetOperation(ptName)
+		diags = append(diags, oDiags...)
 		if rp.ChildExists() {
 			crp := rp.GetChildRopParser()
-			childNode := crp.Parse()
-			if childNode != nil {
+			childNode, cDiags := crp.Parse()
+			diags = append(diags, cDiags...)
+			if rp.mustStop(diags) {
+				return
+			}
+			if childNode.TronconIn != nil {
 				currentNode.AddChild(childNode)
 			}
-			if rp.zone.CreateNodeFromRop {
+			if rp.zone.CreateNodeFromRop && childNode.TronconIn != nil && opType != "" {
 				// define currentNode Operation for childNode
 				nbOpe := crp.pos.row - rp.pos.row
-				currentNode.AddNbOperations(rp.GetValue(colOpe), childNode.TronconIn.Name, nbOpe)
+				if opType == opvocab.OpLove {
+					addLoves(currentNode, nbOpe)
+				} else {
+					currentNode.AddNbOperations(opType, childNode.TronconIn.Name, nbOpe)
+				}
 			}
 			rp.pos.row = crp.pos.row
 		} else {
-			switch rp.GetValue(colOpe) {
-			case "ATTENTE":
+			switch opType {
+			case opvocab.OpWaiting:
 				// Drawer management
-				drawer := rp.GetPosValue(rp.pos.row, acolDrawer)
-				drawerSuffix := drawer
-				parts := strings.Split(drawer, "_")
-				if len(parts) >= 2 {
-					drawerSuffix = strings.Join(parts[len(parts)-2:], "_")
-				}
-				drawerInfo := fmt.Sprintf("%s/%s/%02d",
-					drawerSuffix,
-					rp.GetPosValue(rp.pos.row, acolDrawerLine),
-					rp.GetPosInt(rp.pos.row, acolDrawerCol),
+				drawerInfo := DrawerPosition(
+					rp.GetPosValue(rp.pos.row, rp.layout.ColDrawer),
+					rp.GetPosValue(rp.pos.row, rp.layout.ColDrawerLine),
+					rp.GetPosInt(rp.pos.row, rp.layout.ColDrawerCol),
 				)
 				currentNode.AddDrawerInfo(drawerInfo)
+				rp.zone.Paths = append(rp.zone.Paths, rp.opticalPath(drawerInfo))
 				// Operation management
 				if currentNode.LocationType == "PM" {
-					currentNode.AddOperation(rp.GetValue(colCableIn), "ATTENTE", "", "")
-					if currentNode.TronconIn.NodeSource.LocationType == "PM" {
+					currentNode.AddOperation(rp.GetValue(rp.layout.BlockCableIn), opvocab.OpWaiting, "", "")
+					if currentNode.TronconIn.NodeSource != nil && currentNode.TronconIn.NodeSource.LocationType == "PM" {
 						currentNode.TronconIn.Capa++
 					}
 				} else if rp.zone.CreateNodeFromRop {
-					// check if current Opt. Route is to bu used
-					if rp.IsCurrentRouteForClient() {
-						currentNode.AddOperation("", "ATTENTE", "", "")
+					// check if current Opt. Route is to bu used (reserve routes are also counted if CountReserveOR is set)
+					switch {
+					case rp.IsCurrentRouteForClient():
+						currentNode.AddOperation("", opvocab.OpWaiting, "", "")
+					case rp.IsCurrentRouteForReserve():
+						// fiber is reserved (and not counted as a client one), no Op.
+						currentNode.Stock.Reserved++
+					default:
+						// fiber is stored, no Op.
+						currentNode.Stock.Stored++
 					}
-					// else its operation = love => No Op.
 				}
 
-			case "EPISSURE":
+			case opvocab.OpLove:
+				if rp.zone.CreateNodeFromRop {
+					addLoves(currentNode, 1)
+				}
+
+			case opvocab.OpSplice:
 				if currentNode.LocationType == "PM" {
-					currentNode.AddOperation(rp.GetValue(colCableIn), "EPISSURE", "", "")
-					if currentNode.TronconIn.NodeSource.LocationType == "PM" {
+					currentNode.AddOperation(rp.GetValue(rp.layout.BlockCableIn), opvocab.OpSplice, "", "")
+					if currentNode.TronconIn.NodeSource != nil && currentNode.TronconIn.NodeSource.LocationType == "PM" {
 						currentNode.TronconIn.Capa++
 					}
 				}
@@ -295,9 +360,27 @@ func (rp *RopParser) Parse() *node.Node {
 			rp.pos.row++
 		}
 		// test if pos is still in same Node
-		if rp.GetValue(colPtName) != ptName {
+		if rp.GetValue(rp.layout.BlockPtName) != ptName {
 			inNode = false
 		}
 	}
-	return currentNode
+	return
+}
+
+// skipBlock removes given node from zone and moves RopParser pos after its block (children blocks included)
+func (rp *RopParser) skipBlock(n *node.Node) {
+	if rp.zone.Nodes[n.PtName] == n {
+		delete(rp.zone.Nodes, n.PtName)
+	}
+	for rp.GetValue(rp.layout.BlockPtName) == n.PtName {
+		rp.pos.row++
+	}
+}
+
+// addLoves tallies given number of loved fibers in given node stock. PM nodes fibers are not audited, so their loves are not tallied
+func addLoves(n *node.Node, nb int) {
+	if n.LocationType == "PM" {
+		return
+	}
+	n.Stock.Loved += nb
 }
@@ -0,0 +1,198 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/tealeg/xlsx"
+)
+
+// newTestRopSheet returns a minimal ROP sheet with one PT block attached to PM
+func newTestRopSheet(t *testing.T, service, dist string) *xlsx.Sheet {
+	sheet, err := xlsx.NewFile().AddSheet("TAB_TEST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := DefaultRopLayout()
+	sheet.Cell(0, l.ColFirstChild).SetString("T")
+	sheet.Cell(0, l.ColFirstChild+l.BlockNext+l.ServiceColOffset).SetString(service)
+	sheet.Cell(1, l.ColPmName).SetString("PM1")
+	sheet.Cell(1, l.ColFirstChild+l.BlockTubulure).SetString("T1")
+	sheet.Cell(1, l.ColFirstChild+l.BlockCableIn).SetString("CABLE 1")
+	sheet.Cell(1, l.ColFirstChild+l.BlockName).SetString("N1")
+	sheet.Cell(1, l.ColFirstChild+l.BlockPtName).SetString("PT 1")
+	sheet.Cell(1, l.ColFirstChild+l.BlockDistFromPM).SetString(dist)
+	sheet.Cell(1, l.ColFirstChild+l.BlockOpe).SetString("ATTENTE")
+	sheet.Cell(1, l.ColFirstChild+l.BlockNext+l.ServiceColOffset).SetString("client ftth")
+	return sheet
+}
+
+func TestRopParser_ParseRop(t *testing.T) {
+	z := New()
+	diags := NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+	if len(diags) > 0 {
+		t.Fatalf("ParseRop returned unexpected diagnostics:\n%s", diags.Error())
+	}
+	if len(z.Sro.Children) != 1 || z.Sro.Children[0].PtName != "PT 1" {
+		t.Fatalf("PT 1 should be the only SRO child")
+	}
+	if z.Nodes["PT 1"].DistFromPM != 120 {
+		t.Errorf("unexpected DistFromPM %d", z.Nodes["PT 1"].DistFromPM)
+	}
+}
+
+func TestRopParser_ParseRopDiagnostics(t *testing.T) {
+	z := New()
+	diags := NewRopParser(newTestRopSheet(t, "", "abc"), z).ParseRop()
+	if len(diags) != 2 {
+		t.Fatalf("ParseRop returned %d diagnostics instead of 2:\n%s", len(diags), diags.Error())
+	}
+	if diags[0].Severity != SeverityFatal {
+		t.Errorf("missing service column should be fatal")
+	}
+	if diags[1].Cell != "M2" || diags[1].PtName != "PT 1" || diags[1].Severity != SeverityError {
+		t.Errorf("unexpected distance diagnostic: %s", diags[1].String())
+	}
+	if len(z.Sro.Children) != 1 {
+		t.Errorf("non strict parsing should go on after fatal diagnostic")
+	}
+}
+
+func TestRopParser_ParseRopCableConflict(t *testing.T) {
+	sheet := newTestRopSheet(t, "SERVICE", "120")
+	l := DefaultRopLayout()
+	for _, c := range []int{l.ColFirstChild + l.BlockTubulure, l.ColFirstChild + l.BlockCableIn, l.ColFirstChild + l.BlockName,
+		l.ColFirstChild + l.BlockDistFromPM, l.ColFirstChild + l.BlockOpe} {
+		sheet.Cell(2, c).SetString(sheet.Cell(1, c).Value)
+	}
+	sheet.Cell(2, l.ColPmName).SetString("PM1")
+	sheet.Cell(2, l.ColFirstChild+l.BlockPtName).SetString("PT 2")
+
+	z := New()
+	diags := NewRopParser(sheet, z).ParseRop()
+	if len(diags) != 1 || diags[0].Severity != SeverityFatal || diags[0].PtName != "PT 2" || diags[0].Cell != "I3" {
+		t.Fatalf("cable claimed by two PTs should be reported once as fatal:\n%s", diags.Error())
+	}
+	if _, found := z.Nodes["PT 2"]; found {
+		t.Errorf("PT 2 block should have been skipped")
+	}
+	if len(z.Sro.Children) != 1 || z.Troncons["CABLE 1"].NodeDest != z.Nodes["PT 1"] {
+		t.Errorf("CABLE 1 should still lead to PT 1")
+	}
+}
+
+func TestRopParser_ParseRopUnknownOperation(t *testing.T) {
+	z := New()
+	sheet := newTestRopSheet(t, "SERVICE", "120")
+	l := z.RopLayout
+	sheet.Cell(1, l.ColFirstChild+l.BlockOpe).SetString("DERIVATION")
+	diags := NewRopParser(sheet, z).ParseRop()
+	if len(diags) != 1 || diags[0].Severity != SeverityWarning || diags[0].PtName != "PT 1" {
+		t.Fatalf("unknown operation should be reported once:\n%s", diags.Error())
+	}
+	if len(z.Nodes["PT 1"].Operation) != 0 {
+		t.Errorf("unknown operation should not be counted: %v", z.Nodes["PT 1"].Operation)
+	}
+
+	z = New()
+	z.Vocabulary[opvocab.SourceROP] = map[string]string{"derivation": opvocab.OpWaiting}
+	diags = NewRopParser(sheet, z).ParseRop()
+	if len(diags) > 0 || z.Nodes["PT 1"].Operation[opvocab.OpWaiting] != 1 {
+		t.Errorf("ROP synonym should be counted as Attente: %v\n%s", z.Nodes["PT 1"].Operation, diags.Error())
+	}
+}
+
+func TestRopParser_ParseRopFiberStock(t *testing.T) {
+	sheet := newTestRopSheet(t, "SERVICE", "120")
+	l := DefaultRopLayout()
+	for i, service := range []string{"reserve", "reserve", "exploitation"} {
+		row := 2 + i
+		for _, c := range []int{l.ColFirstChild + l.BlockTubulure, l.ColFirstChild + l.BlockCableIn, l.ColFirstChild + l.BlockName,
+			l.ColFirstChild + l.BlockPtName, l.ColFirstChild + l.BlockDistFromPM, l.ColFirstChild + l.BlockOpe} {
+			sheet.Cell(row, c).SetString(sheet.Cell(1, c).Value)
+		}
+		sheet.Cell(row, l.ColFirstChild+l.BlockNext+l.ServiceColOffset).SetString(service)
+	}
+	sheet.Cell(5, l.ColFirstChild+l.BlockOpe).SetString("LOVE")
+	for _, c := range []int{l.ColFirstChild + l.BlockTubulure, l.ColFirstChild + l.BlockCableIn, l.ColFirstChild + l.BlockName,
+		l.ColFirstChild + l.BlockPtName, l.ColFirstChild + l.BlockDistFromPM} {
+		sheet.Cell(5, c).SetString(sheet.Cell(1, c).Value)
+	}
+
+	for _, tc := range []struct {
+		countReserve bool
+		nbWaiting    int
+		expected     node.FiberStock
+	}{
+		{false, 1, node.FiberStock{Reserved: 2, Loved: 1, Stored: 1}},
+		{true, 3, node.FiberStock{Loved: 1, Stored: 1}},
+	} {
+		z := New()
+		rp := NewRopParser(sheet, z)
+		rp.CountReserveOR = tc.countReserve
+		if diags := rp.ParseRop(); len(diags) > 0 {
+			t.Fatalf("ParseRop returned unexpected diagnostics:\n%s", diags.Error())
+		}
+		pt := z.Nodes["PT 1"]
+		if pt.Operation[opvocab.OpWaiting] != tc.nbWaiting || pt.Stock != tc.expected {
+			t.Errorf("CountReserveOR %v : unexpected %d attentes and stock %+v", tc.countReserve, pt.Operation[opvocab.OpWaiting], pt.Stock)
+		}
+	}
+}
+
+func TestAddLoves(t *testing.T) {
+	pm, pbo := node.NewNode(), node.NewNode()
+	pm.LocationType, pbo.LocationType = "PM", "PBO"
+	for _, n := range []*node.Node{pm, pbo} {
+		addLoves(n, 3)
+	}
+	if pm.Stock.Loved != 0 || pbo.Stock.Loved != 3 {
+		t.Errorf("unexpected PM loves %d and PBO loves %d", pm.Stock.Loved, pbo.Stock.Loved)
+	}
+}
+
+func TestRopParser_ParseRopStrict(t *testing.T) {
+	z := New()
+	rp := NewRopParser(newTestRopSheet(t, "", "120"), z)
+	rp.Strict = true
+	diags := rp.ParseRop()
+	if !diags.HasFatal() || len(diags) != 1 {
+		t.Fatalf("strict parsing should stop on first fatal diagnostic:\n%s", diags.Error())
+	}
+	if len(z.Sro.Children) != 0 {
+		t.Errorf("strict parsing should not populate zone after fatal diagnostic")
+	}
+}
+
+func TestGetRopLayout(t *testing.T) {
+	layout, err := GetRopLayout("Standard")
+	if err != nil {
+		t.Fatalf("GetRopLayout returned unexpected: %s", err.Error())
+	}
+	if layout.Name != "standard" || !layout.IsRopSheet("TAB_SRO") {
+		t.Errorf("unexpected standard layout: %+v", layout)
+	}
+	if !layout.IsClientService(" Client FTTH 1") || layout.IsClientService("reserve") || !layout.IsReserveService("RESERVE OR") {
+		t.Errorf("unexpected service matching rules")
+	}
+	layout.ClientServices[0] = "altered"
+	if RopLayouts["standard"].ClientServices[0] != "client ftth" {
+		t.Errorf("altering returned layout should not alter built-in one")
+	}
+	for name, sheetName := range map[string]string{"axians": "TAB_PM04", "sogetrel": "TAB_SRO"} {
+		layout, err := GetRopLayout(name)
+		if err != nil {
+			t.Fatalf("GetRopLayout returned unexpected: %s", err.Error())
+		}
+		if layout.Name != name || !layout.IsRopSheet(sheetName) || layout.ColFirstChild != DefaultRopLayout().ColFirstChild {
+			t.Errorf("unexpected %s layout: %+v", name, layout)
+		}
+	}
+	if !RopLayouts["axians"].IsReserveService("Réserve OR") || RopLayouts["sogetrel"].IsRopSheet("TAB_PM") {
+		t.Errorf("unexpected operator layouts rules")
+	}
+	if _, err := GetRopLayout("unknown"); err == nil {
+		t.Errorf("GetRopLayout should fail on unknown layout")
+	}
+}
@@ -0,0 +1,180 @@
+package zone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+)
+
+// PathStep is a node traversed by an optical path : fiber enters Node through Troncon (in tube Tube), and Ope is done on it
+type PathStep struct {
+	Node    *node.Node
+	Troncon *node.Troncon // nil for path first node (PM)
+	Tube    string
+	Ope     string          // canonical operation type (opvocab.OpSplice, opvocab.OpPassage, opvocab.OpWaiting, ...), or label if unknown
+	Fiber   *node.Operation // fiber level operation done in Node (input and output cable/tube/fiber), nil if node splice plan is not available
+}
+
+func (ps PathStep) String() string {
+	if ps.Troncon == nil {
+		return ps.Node.PtName
+	}
+	if ps.Fiber != nil {
+		return fmt.Sprintf("=[%s]=> %s (%s)", ps.Fiber.In(), ps.Node.PtName, ps.Ope)
+	}
+	return fmt.Sprintf("=[%s %s]=> %s (%s)", ps.Troncon.Name, ps.Tube, ps.Node.PtName, ps.Ope)
+}
+
+// OpticalPath is the route of a fiber from its PM drawer position down to its attente node.
+//
+// Route is the node level route described by the ROP file row. Steps follow the fiber level operations of splice plans (see node.TraceFiber),
+// starting from fiber Fiber (in route first step tube) of the route first troncon, and are completed by Route steps where splice plans are missing
+type OpticalPath struct {
+	Drawer  string // drawer/line/column position, as in Mesures sheet Conn. columns
+	Service string
+	Fiber   string // fiber number on route first troncon, empty if unknown (Steps are then the Route ones)
+	Route   []PathStep
+	Steps   []PathStep
+}
+
+// DrawerPosition returns the drawer/line/column position label for given ROP drawer, line and column values
+func DrawerPosition(drawer, line string, col int) string {
+	drawerSuffix := drawer
+	parts := strings.Split(drawer, "_")
+	if len(parts) >= 2 {
+		drawerSuffix = strings.Join(parts[len(parts)-2:], "_")
+	}
+	return fmt.Sprintf("%s/%s/%02d", drawerSuffix, line, col)
+}
+
+// End returns the receiver last node (where the fiber is left waiting)
+func (op *OpticalPath) End() *node.Node {
+	return op.Steps[len(op.Steps)-1].Node
+}
+
+// Splices returns the receiver steps where the fiber is spliced
+func (op *OpticalPath) Splices() []PathStep {
+	res := []PathStep{}
+	for _, step := range op.Steps {
+		if step.Ope == opvocab.OpSplice {
+			res = append(res, step)
+		}
+	}
+	return res
+}
+
+func (op *OpticalPath) String() string {
+	steps := make([]string, len(op.Steps))
+	for i, step := range op.Steps {
+		steps[i] = step.String()
+	}
+	return fmt.Sprintf("%s : %s", op.Drawer, strings.Join(steps, " "))
+}
+
+// resolve sets the receiver Steps from the splice plans fiber level operations, following its fiber from the route first troncon
+func (op *OpticalPath) resolve() {
+	op.Steps = op.Route
+	if len(op.Route) < 2 || op.Route[1].Troncon == nil || op.Fiber == "" {
+		return
+	}
+	hops := op.Route[1].Node.TraceFiber(op.Route[1].Troncon.Name, op.Route[1].Tube, op.Fiber)
+	if len(hops) == 0 {
+		return
+	}
+	routeStep := func(n *node.Node) int {
+		for i, step := range op.Route {
+			if step.Node == n {
+				return i
+			}
+		}
+		return -1
+	}
+	steps := []PathStep{op.Route[0]}
+	for _, hop := range hops {
+		step := PathStep{
+			Node:    hop.Node,
+			Troncon: hop.Node.TronconIn,
+			Tube:    hop.Operation.TubeIn,
+			Ope:     hop.Operation.Type,
+			Fiber:   hop.Operation,
+		}
+		if step.Ope == "" {
+			step.Ope = hop.Operation.Label
+		}
+		if i := routeStep(hop.Node); i >= 0 && step.Tube == "" {
+			step.Tube = op.Route[i].Tube
+		}
+		steps = append(steps, step)
+	}
+	// complete with ROP route beyond last node having a splice plan
+	if i := routeStep(hops[len(hops)-1].Node); i >= 0 {
+		steps = append(steps, op.Route[i+1:]...)
+	}
+	op.Steps = steps
+}
+
+// ResolvePaths sets the receiver optical paths steps from the nodes splice plans fiber level operations (see OpticalPath)
+func (z *Zone) ResolvePaths() {
+	for _, op := range z.Paths {
+		op.resolve()
+	}
+}
+
+// TracePath returns the optical path starting at given PM drawer position (drawer/line/column, as in Mesures sheet)
+func (z *Zone) TracePath(position string) (*OpticalPath, error) {
+	if len(z.Paths) == 0 {
+		return nil, fmt.Errorf("no optical path defined (ROP file is required)")
+	}
+	for _, op := range z.Paths {
+		if strings.EqualFold(op.Drawer, strings.TrimSpace(position)) {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("no optical path found for drawer position '%s'", position)
+}
+
+// PathsTo returns the optical paths ending on given node attentes (reverse lookup from PBO)
+func (z *Zone) PathsTo(ptName string) []*OpticalPath {
+	res := []*OpticalPath{}
+	for _, op := range z.Paths {
+		if op.End().PtName == ptName {
+			res = append(res, op)
+		}
+	}
+	return res
+}
+
+// opticalPath returns the optical path described by current ROP row, from PM down to current block (its Steps are set by Zone.ResolvePaths).
+//
+// Path fiber is read in route first block fiber column. It is left empty if layout has no fiber column (path is then traced at route level only)
+func (rp *RopParser) opticalPath(drawer string) *OpticalPath {
+	row := rp.pos.row
+	op := &OpticalPath{
+		Drawer:  drawer,
+		Service: strings.TrimSpace(rp.GetPosValue(row, rp.serviceCol)),
+		Route:   []PathStep{{Node: rp.zone.Sro}},
+	}
+	for col := rp.layout.ColFirstChild; col <= rp.pos.col; col += rp.layout.BlockNext {
+		n := rp.zone.Nodes[rp.GetPosValue(row, col+rp.layout.BlockPtName)]
+		if n == nil {
+			continue
+		}
+		ope := rp.GetPosValue(row, col+rp.layout.BlockOpe)
+		if opType, known := rp.zone.Vocabulary.Canonical(opvocab.SourceROP, ope); known {
+			ope = opType
+		}
+		op.Route = append(op.Route, PathStep{
+			Node:    n,
+			Troncon: n.TronconIn,
+			Tube:    rp.GetPosValue(row, col+rp.layout.BlockTubulure),
+			Ope:     ope,
+		})
+	}
+	op.Steps = op.Route
+	if rp.layout.BlockFiber >= 0 {
+		op.Fiber = strings.TrimSpace(rp.GetPosValue(row, rp.layout.ColFirstChild+rp.layout.BlockFiber))
+	}
+	return op
+}
@@ -0,0 +1,53 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+)
+
+func TestZone_TracePath(t *testing.T) {
+	z := New()
+	sheet := newTestRopSheet(t, "SERVICE", "120")
+	l := DefaultRopLayout()
+	sheet.Cell(1, l.ColDrawer).SetString("PM1_TIROIR_1")
+	sheet.Cell(1, l.ColDrawerLine).SetString("A")
+	sheet.Cell(1, l.ColDrawerCol).SetInt(3)
+	NewRopParser(sheet, z).ParseRop()
+
+	path, err := z.TracePath("TIROIR_1/A/03")
+	if err != nil {
+		t.Fatalf("TracePath returned unexpected error: %s", err.Error())
+	}
+	if len(path.Steps) != 2 || path.Steps[0].Node != z.Sro || path.End().PtName != "PT 1" || path.Steps[1].Troncon.Name != "CABLE 1" {
+		t.Errorf("unexpected optical path %s", path.String())
+	}
+	if path.Service != "client ftth" {
+		t.Errorf("unexpected path service '%s'", path.Service)
+	}
+	if paths := z.PathsTo("PT 1"); len(paths) != 1 || paths[0] != path {
+		t.Errorf("PathsTo returned unexpected %v", paths)
+	}
+	if _, err := z.TracePath("TIROIR_1/A/04"); err == nil {
+		t.Errorf("TracePath should fail on unknown drawer position")
+	}
+	// standard layout has no fiber column : path stays at route level, even with a splice plan
+	z.Nodes["PT 1"].AddFiber(&node.Operation{Label: "Attente", CableIn: "CABLE 1", TubeIn: "2", FiberIn: "1"}, z.Vocabulary)
+	z.ResolvePaths()
+	if path.Fiber != "" || path.Steps[1].Fiber != nil {
+		t.Errorf("unexpected path fiber '%s' without ROP fiber column", path.Fiber)
+	}
+
+	// splice plan fiber level operations
+	z = New()
+	z.RopLayout.BlockFiber = 1
+	sheet.Cell(1, l.ColFirstChild+1).SetString("1")
+	NewRopParser(sheet, z).ParseRop()
+	z.Nodes["PT 1"].AddFiber(&node.Operation{Label: "Attente", CableIn: "CABLE 1", TubeIn: "T2", FiberIn: "1"}, z.Vocabulary)
+	z.Nodes["PT 1"].AddFiber(&node.Operation{Label: "Attente", CableIn: "CABLE 1", TubeIn: "T1", FiberIn: "1"}, z.Vocabulary)
+	z.ResolvePaths()
+	path, _ = z.TracePath("TIROIR_1/A/03")
+	if path == nil || path.Fiber != "1" || len(path.Steps) != 2 || path.Steps[1].Fiber == nil || path.Steps[1].Fiber.In() != "CABLE 1/T1/1" || path.Steps[1].Tube != "T1" {
+		t.Errorf("unexpected fiber level optical path %s", path.String())
+	}
+}
@@ -0,0 +1,372 @@
+package zone
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/chantiersalsace/xlsreader"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	sheetPlanning string = "Planning"
+
+	// BPU Prices sheet layout (as used by parsesuivi)
+	bpuPriceSheetName string = "Prices"
+	colBPUActivity    int    = 0
+	colBPUCategory    int    = 1
+	colBPUWork        int    = 5
+)
+
+// Productivity defines a field team daily throughput, used to plan interventions
+type Productivity struct {
+	SplicesPerDay float64 `json:"splicesPerDay" yaml:"splicesPerDay"` // splices per day (Racco)
+	MetersPerDay  float64 `json:"metersPerDay" yaml:"metersPerDay"`   // pulled cable meters per day (Tirage)
+	FibersPerDay  float64 `json:"fibersPerDay" yaml:"fibersPerDay"`   // measured fibers per day (Mesures)
+	MaxSpread     int     `json:"maxSpread" yaml:"maxSpread"`         // maximum distance from PM gap (m) between works of a same intervention (no limit if 0)
+	WorkPerDay    float64 `json:"workPerDay" yaml:"workPerDay"`       // BPU work units per day, used to derive throughputs from BPU Work values
+}
+
+// DefaultProductivity returns the usual field team throughputs
+func DefaultProductivity() Productivity {
+	return Productivity{
+		SplicesPerDay: 96,
+		MetersPerDay:  1500,
+		FibersPerDay:  48,
+		MaxSpread:     2000,
+		WorkPerDay:    1,
+	}
+}
+
+// ProductivityFromBPU returns given productivity, with throughputs derived from given BPU file Work values (work per splice for Racco "BPE Splice" category,
+// per meter for Tirage "Tirage Souterain" category and per fiber for Mesures "Mesure" category) : throughput is WorkPerDay / Work.
+// Throughputs of categories not found in BPU file are left unchanged
+func ProductivityFromBPU(file string, p Productivity) (Productivity, error) {
+	xf, err := xlsreader.OpenFile(file)
+	if err != nil {
+		return p, err
+	}
+	sheet := xf.Sheet[bpuPriceSheetName]
+	if sheet == nil {
+		return p, fmt.Errorf("could not find '%s' sheet in BPU file", bpuPriceSheetName)
+	}
+	throughputs := []struct {
+		activity, category string
+		perDay             *float64
+	}{
+		{"racco", "bpe splice", &p.SplicesPerDay},
+		{"tirage", "tirage souterain", &p.MetersPerDay},
+		{"mesures", "mesure", &p.FibersPerDay},
+	}
+	for _, tp := range throughputs {
+		for row := 1; row < sheet.MaxRow; row++ {
+			activity := strings.ToLower(strings.TrimSpace(sheet.Cell(row, colBPUActivity).Value))
+			if activity == "" {
+				break
+			}
+			if activity != tp.activity || strings.ToLower(strings.TrimSpace(sheet.Cell(row, colBPUCategory).Value)) != tp.category {
+				continue
+			}
+			work, err := sheet.Cell(row, colBPUWork).Float()
+			if err != nil {
+				return p, fmt.Errorf("could not get work info in '%s!%s'", bpuPriceSheetName, xlsx.GetCellIDStringFromCoords(colBPUWork, row))
+			}
+			if work > 0 {
+				*tp.perDay = p.WorkPerDay / work
+			}
+			break
+		}
+	}
+	return p, nil
+}
+
+// PlannedWork is a work item (cable pulling, node junction or node measurement) of an Intervention
+type PlannedWork struct {
+	Site       string // cable first troncon name, or node PT name
+	Address    string
+	DistFromPM int
+	Quantity   float64
+	Unit       string
+
+	status **node.FieldStatus // work field status, where planned trip is set
+}
+
+// Intervention groups works done by a field team during one trip (déplacement) : one day, or more if a single work exceeds daily throughput
+type Intervention struct {
+	Trip     string // N° Déplacement
+	Activity string // Tirage, Racco or Mesures
+	Day      int    // first planned day (1 based)
+	Days     int    // number of days
+	Works    []*PlannedWork
+}
+
+// planTask is a work to be planned, which can not start before earliest day
+type planTask struct {
+	work     *PlannedWork
+	earliest int
+	end      int // planned last day
+}
+
+// isPlannable returns true if work having given field status is still to be done, and is not already assigned to a trip
+func isPlannable(fs *node.FieldStatus) bool {
+	return fs == nil || (fs.Status != ripconst.StateDone && fs.Status != ripconst.StateCanceled && fs.Trip == "")
+}
+
+// planActivity groups given tasks (in order) into interventions : a task joins current intervention if it may start on its day, if daily throughput
+// is not exceeded and if its distance from PM stays within maxSpread of the other ones. A task exceeding daily throughput spans several days.
+func planActivity(activity string, tasks []*planTask, perDay float64, maxSpread int) []*Intervention {
+	res := []*Intervention{}
+	var cur *Intervention
+	day, load, minDist, maxDist := 1, 0.0, 0, 0
+	for _, t := range tasks {
+		w := t.work
+		if cur != nil {
+			spread := maxDist - w.DistFromPM
+			if w.DistFromPM-minDist > spread {
+				spread = w.DistFromPM - minDist
+			}
+			if t.earliest > cur.Day || load+w.Quantity > perDay || (maxSpread > 0 && spread > maxSpread) {
+				day = cur.Day + cur.Days
+				cur = nil
+			}
+		}
+		if cur == nil {
+			if t.earliest > day {
+				day = t.earliest
+			}
+			cur = &Intervention{Activity: activity, Day: day, Days: 1}
+			res = append(res, cur)
+			load, minDist, maxDist = 0, w.DistFromPM, w.DistFromPM
+		}
+		cur.Works = append(cur.Works, w)
+		load += w.Quantity
+		if w.DistFromPM < minDist {
+			minDist = w.DistFromPM
+		}
+		if w.DistFromPM > maxDist {
+			maxDist = w.DistFromPM
+		}
+		if load > perDay {
+			cur.Days = int(math.Ceil(load / perDay))
+		}
+		t.end = cur.Day + cur.Days - 1
+		if cur.Days > 1 {
+			day = cur.Day + cur.Days
+			cur = nil
+		}
+	}
+	return res
+}
+
+// treeNodes returns zone nodes in Racco sheet order (depth first from SRO, or from node roots)
+func (z *Zone) treeNodes() []*node.Node {
+	res := []*node.Node{}
+	var walk func(n *node.Node)
+	walk = func(n *node.Node) {
+		res = append(res, n)
+		for _, cn := range n.GetChildren() {
+			walk(cn)
+		}
+	}
+	if len(z.Sro.Children) > 0 {
+		walk(z.Sro)
+	} else {
+		for _, rootnode := range z.NodeRoots {
+			walk(rootnode)
+		}
+	}
+	return res
+}
+
+// Plan groups zone works still to be done into field interventions, according to zone Productivity and enabled activities :
+// cable pulling (in zone cables order), node junctions and node measurements (in zone tree order, so that interventions gather neighbour nodes).
+//
+// A node junction is planned after the pulling of its incoming and outgoing troncons (same rule as UpdateReadiness), and a node measurement after the
+// junctions of all nodes along its path.
+// Works already assigned to a trip in suivi workbook (N° Déplacement) are not planned again (and do not delay depending works). Each planned work gets
+// its intervention number as field status N° Déplacement, and interventions are numbered after the highest numeric trip already defined
+func (z *Zone) Plan() ([]*Intervention, error) {
+	p := z.Productivity
+	res := []*Intervention{}
+	// interventions are numbered after already typed trips
+	maxTrip := 0
+	checkTrip := func(fs *node.FieldStatus) {
+		if fs == nil {
+			return
+		}
+		if trip, err := strconv.Atoi(fs.Trip); err == nil && trip > maxTrip {
+			maxTrip = trip
+		}
+	}
+	for _, c := range z.Cables {
+		checkTrip(c.Status)
+	}
+	nodes := z.treeNodes()
+	for _, n := range nodes {
+		checkTrip(n.JunctionStatus)
+		checkTrip(n.MeasurementStatus)
+	}
+
+	// Tirage
+	pullEnd := map[*node.Troncon]int{}
+	if z.DoPulling {
+		tasks := []*planTask{}
+		cables := []*node.Cable{}
+		for _, c := range z.Cables {
+			if c.CableType() == "" || !isPlannable(c.Status) {
+				continue
+			}
+			nBeg := c.Troncons[0].NodeSource
+			tasks = append(tasks, &planTask{work: &PlannedWork{
+				Site:       c.Troncons[0].Name,
+				Address:    nBeg.Address,
+				DistFromPM: nBeg.DistFromPM,
+				Quantity:   float64(c.RequiredLength()),
+				Unit:       "m",
+				status:     &c.Status,
+			}})
+			cables = append(cables, c)
+		}
+		if len(tasks) > 0 && p.MetersPerDay <= 0 {
+			return nil, fmt.Errorf("pulling productivity (metersPerDay) is not defined")
+		}
+		res = append(res, planActivity(sheetTirage, tasks, p.MetersPerDay, p.MaxSpread)...)
+		for i, t := range tasks {
+			for _, tr := range cables[i].Troncons {
+				pullEnd[tr] = t.end
+			}
+		}
+	}
+
+	// Racco
+	junctionEnd := map[string]int{}
+	if z.DoJunctions {
+		tasks := []*planTask{}
+		for _, n := range nodes {
+			nbEpi, _ := n.GetNumbers()
+			if nbEpi == 0 || !isPlannable(n.JunctionStatus) {
+				continue
+			}
+			if z.junctionCanceled(n) {
+				continue
+			}
+			earliest := 0
+			for _, tr := range junctionTroncons(n) {
+				if pullEnd[tr] > earliest {
+					earliest = pullEnd[tr]
+				}
+			}
+			tasks = append(tasks, &planTask{
+				work: &PlannedWork{
+					Site:       n.PtName,
+					Address:    n.Address,
+					DistFromPM: n.DistFromPM,
+					Quantity:   float64(nbEpi),
+					Unit:       "épissures",
+					status:     &n.JunctionStatus,
+				},
+				earliest: earliest + 1,
+			})
+		}
+		if len(tasks) > 0 && p.SplicesPerDay <= 0 {
+			return nil, fmt.Errorf("junction productivity (splicesPerDay) is not defined")
+		}
+		res = append(res, planActivity(sheetRacco, tasks, p.SplicesPerDay, p.MaxSpread)...)
+		for _, t := range tasks {
+			junctionEnd[t.work.Site] = t.end
+		}
+	}
+
+	// Mesures
+	if z.DoMeasurement {
+		tasks := []*planTask{}
+		for _, n := range nodes {
+			nbFiber := n.GetToBeMeasuredFiber()
+			if nbFiber == 0 || !isPlannable(n.MeasurementStatus) {
+				continue
+			}
+			earliest := 0
+			for _, ptName := range measurementJunctions(n) {
+				if junctionEnd[ptName] > earliest {
+					earliest = junctionEnd[ptName]
+				}
+			}
+			tasks = append(tasks, &planTask{
+				work: &PlannedWork{
+					Site:       n.PtName,
+					Address:    n.Address,
+					DistFromPM: n.DistFromPM,
+					Quantity:   float64(nbFiber),
+					Unit:       "fibres",
+					status:     &n.MeasurementStatus,
+				},
+				earliest: earliest + 1,
+			})
+		}
+		if len(tasks) > 0 && p.FibersPerDay <= 0 {
+			return nil, fmt.Errorf("measurement productivity (fibersPerDay) is not defined")
+		}
+		res = append(res, planActivity(sheetMesures, tasks, p.FibersPerDay, p.MaxSpread)...)
+	}
+
+	actOrder := map[string]int{sheetTirage: 0, sheetRacco: 1, sheetMesures: 2}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Day != res[j].Day {
+			return res[i].Day < res[j].Day
+		}
+		return actOrder[res[i].Activity] < actOrder[res[j].Activity]
+	})
+	for i, itv := range res {
+		itv.Trip = strconv.Itoa(maxTrip + i + 1)
+		for _, w := range itv.Works {
+			if *w.status == nil {
+				*w.status = &node.FieldStatus{Status: ripconst.StateToDo}
+			}
+			(*w.status).Trip = itv.Trip
+		}
+	}
+	z.Interventions = res
+	return res, nil
+}
+
+// addPlanningSheet adds a Planning sheet listing zone interventions day by day, one row per planned work
+func (z *Zone) addPlanningSheet(xls *xlsx.File) error {
+	sheet, err := xls.AddSheet(sheetPlanning)
+	if err != nil {
+		return err
+	}
+	cols := []col{
+		{"Jour", 8},
+		{"Nb Jours", 10},
+		{"N° Déplacement", 15},
+		{"Activité", 12},
+		{"Site", 15},
+		{"Adresse", 40},
+		{"Distance", 12},
+		{"Quantité", 12},
+		{"Unité", 12},
+	}
+	addHeaderRow(sheet, cols)
+	colors := map[string]string{sheetTirage: colorGreen, sheetRacco: colorBlue, sheetMesures: colorRed}
+	for _, itv := range z.Interventions {
+		for _, w := range itv.Works {
+			r := sheet.AddRow()
+			r.AddCell().SetInt(itv.Day)
+			r.AddCell().SetInt(itv.Days)
+			r.AddCell().SetString(itv.Trip)
+			r.AddCell().SetString(itv.Activity)
+			r.AddCell().SetString(w.Site)
+			r.AddCell().SetString(w.Address)
+			r.AddCell().SetInt(w.DistFromPM)
+			r.AddCell().SetFloat(w.Quantity)
+			r.AddCell().SetString(w.Unit)
+			fillRow(r, colors[itv.Activity])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package zone
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+	"github.com/tealeg/xlsx"
+)
+
+// newTestPlanningZone returns a zone SRO -> PT 1 -> PT 2, with one cable per troncon
+func newTestPlanningZone() *Zone {
+	z := New()
+	z.DoPulling, z.DoJunctions, z.DoMeasurement, z.DoEline, z.DoOtherThanEline = true, true, true, true, true
+	z.Productivity = Productivity{SplicesPerDay: 96, MetersPerDay: 1500, FibersPerDay: 48}
+
+	pt1, pt2 := node.NewNode(), node.NewNode()
+	pt1.PtName, pt1.DistFromPM = "PT 1", 300
+	pt1.Operation["Epissure->TR-B"] = 60
+	pt1.Operation["Attente"] = 4
+	pt2.PtName, pt2.DistFromPM, pt2.SplicePT = "PT 2", 800, []string{"PT 1"}
+	pt2.Operation["Epissure"] = 50
+	pt2.Operation["Attente"] = 12
+	pt2.JunctionStatus = &node.FieldStatus{Status: ripconst.StateToDo, Trip: "12"}
+	z.Sro.Children = []*node.Node{pt1}
+	pt1.Children = []*node.Node{pt2}
+	z.Nodes[pt1.PtName], z.Nodes[pt2.PtName] = pt1, pt2
+
+	for _, tc := range []struct {
+		name     string
+		from, to *node.Node
+		length   int
+	}{
+		{"TR-A", z.Sro, pt1, 300},
+		{"TR-B", pt1, pt2, 2000},
+	} {
+		tr := node.NewTroncon(tc.name)
+		tr.CableType, tr.UndergroundLength = "CABLE 144", tc.length
+		tr.NodeSource, tr.NodeDest = tc.from, tc.to
+		tc.from.TronconsOut[tc.name] = tr
+		tc.to.TronconIn = tr
+		c := node.NewCable(tr)
+		c.AddTroncon(tr, 0)
+		z.Cables.Add(c)
+	}
+	return z
+}
+
+func TestZone_Plan(t *testing.T) {
+	z := newTestPlanningZone()
+	interventions, err := z.Plan()
+	if err != nil {
+		t.Fatalf("Plan returned unexpected: %s", err.Error())
+	}
+	expected := []struct {
+		trip     string
+		activity string
+		day      int
+		days     int
+		sites    string
+	}{
+		{"13", sheetTirage, 1, 1, "TR-A"},
+		{"14", sheetTirage, 2, 2, "TR-B"},       // 2000m at 1500m/day
+		{"15", sheetRacco, 4, 1, "PT 1"},        // after TR-B pulling (PT 2 junction is already on trip 12)
+		{"16", sheetMesures, 5, 1, "PT 1,PT 2"}, // after PT 1 junction
+	}
+	if len(interventions) != len(expected) {
+		t.Fatalf("unexpected number of interventions %d", len(interventions))
+	}
+	for i, exp := range expected {
+		itv := interventions[i]
+		sites := []string{}
+		for _, w := range itv.Works {
+			sites = append(sites, w.Site)
+		}
+		if itv.Trip != exp.trip || itv.Activity != exp.activity || itv.Day != exp.day || itv.Days != exp.days || strings.Join(sites, ",") != exp.sites {
+			t.Errorf("intervention %d is %s %s day %d (%d) instead of %+v", i, itv.Trip, itv.Activity, itv.Day, itv.Days, exp)
+		}
+	}
+
+	if z.Cables[1].Status == nil || z.Cables[1].Status.Trip != "14" || z.Cables[1].Status.Status != ripconst.StateToDo {
+		t.Errorf("unexpected cable status %+v", z.Cables[1].Status)
+	}
+	if pt2 := z.Nodes["PT 2"]; pt2.JunctionStatus.Trip != "12" || pt2.MeasurementStatus.Trip != "16" {
+		t.Errorf("unexpected PT 2 trips '%s', '%s'", pt2.JunctionStatus.Trip, pt2.MeasurementStatus.Trip)
+	}
+
+	xls, err := z.buildXLS()
+	if err != nil {
+		t.Fatalf("buildXLS returned unexpected: %s", err.Error())
+	}
+	if sheet := xls.Sheet[sheetPlanning]; sheet == nil || sheet.MaxRow != 6 || sheet.Cell(3, 2).Value != "15" {
+		t.Errorf("unexpected Planning sheet")
+	}
+	if sheet := xls.Sheet[sheetRacco]; sheet == nil || sheet.Cell(1, colRaccoStatus+2).Value != "" {
+		t.Errorf("unexpected Racco sheet trip for SRO")
+	}
+}
+
+func TestProductivityFromBPU(t *testing.T) {
+	xf := xlsx.NewFile()
+	sheet, err := xf.AddSheet(bpuPriceSheetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r, row := range [][]string{
+		{"Activity", "Category", "Name", "Size", "Price", "Work"},
+		{"Racco", "BPE", "Boitier BPE", "1", "100", "1"},
+		{"Racco", "BPE Splice", "Epissure BPE", "1", "5", "0.0125"},
+		{"Tirage", "Tirage Souterain", "Tirage 144", "144", "1", "0.0008"},
+	} {
+		for c, value := range row {
+			sheet.Cell(r, c).SetString(value)
+		}
+	}
+	file := filepath.Join(t.TempDir(), "BPU.xlsx")
+	err = xf.Save(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ProductivityFromBPU(file, DefaultProductivity())
+	if err != nil {
+		t.Fatalf("ProductivityFromBPU returned unexpected: %s", err.Error())
+	}
+	if p.SplicesPerDay != 80 || p.MetersPerDay != 1250 || p.FibersPerDay != DefaultProductivity().FibersPerDay {
+		t.Errorf("unexpected productivity %+v", p)
+	}
+}
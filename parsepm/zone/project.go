@@ -0,0 +1,206 @@
+package zone
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+	"github.com/tealeg/xlsx"
+)
+
+// Project gathers the zones of several PM / SRO (ex: a whole commune). Each zone keeps its own nodes and troncons, so that same PT or troncon names
+// can be used by different zones
+type Project struct {
+	Name  string
+	Zones []*ProjectZone
+}
+
+// ProjectZone is a named zone of a Project
+type ProjectZone struct {
+	Name string
+	Zone *Zone
+}
+
+// NewProject returns an empty project
+func NewProject(name string) *Project {
+	return &Project{Name: name}
+}
+
+// Add appends given zone to the receiver project. Zone names must be unique
+func (p *Project) Add(name string, z *Zone) error {
+	if p.Zone(name) != nil {
+		return fmt.Errorf("zone '%s' already defined in project", name)
+	}
+	p.Zones = append(p.Zones, &ProjectZone{Name: name, Zone: z})
+	return nil
+}
+
+// Zone returns the project zone having given name (nil if not found)
+func (p *Project) Zone(name string) *Zone {
+	for _, pz := range p.Zones {
+		if pz.Name == name {
+			return pz.Zone
+		}
+	}
+	return nil
+}
+
+// Node returns the node having given PT name in given project zone (nil if not found)
+func (p *Project) Node(zoneName, ptName string) *node.Node {
+	z := p.Zone(zoneName)
+	if z == nil {
+		return nil
+	}
+	return z.Nodes[ptName]
+}
+
+// Totals sums up zone works per activity (Tirage, Racco and Mesures), along with their progress (done) and readiness (ready)
+type Totals struct {
+	Nodes int
+
+	Cables      int
+	CableLength int
+	CablesDone  int
+
+	Junctions      int // nodes with splices
+	Splices        int
+	JunctionsDone  int
+	JunctionsReady int
+
+	Measurements      int // measured nodes
+	Fibers            int
+	MeasurementsDone  int
+	MeasurementsReady int
+}
+
+// Add adds given totals to the receiver ones
+func (t *Totals) Add(o Totals) {
+	t.Nodes += o.Nodes
+	t.Cables += o.Cables
+	t.CableLength += o.CableLength
+	t.CablesDone += o.CablesDone
+	t.Junctions += o.Junctions
+	t.Splices += o.Splices
+	t.JunctionsDone += o.JunctionsDone
+	t.JunctionsReady += o.JunctionsReady
+	t.Measurements += o.Measurements
+	t.Fibers += o.Fibers
+	t.MeasurementsDone += o.MeasurementsDone
+	t.MeasurementsReady += o.MeasurementsReady
+}
+
+// Totals returns the receiver works totals (only enabled activities are counted). Nodes readiness must be up to date (see UpdateReadiness)
+func (z *Zone) Totals() Totals {
+	t := Totals{Nodes: len(z.Nodes)}
+	if z.DoPulling {
+		for _, c := range z.Cables {
+			if c.CableType() == "" {
+				continue
+			}
+			t.Cables++
+			t.CableLength += c.RequiredLength()
+			if c.Status != nil && c.Status.Status == ripconst.StateDone {
+				t.CablesDone++
+			}
+		}
+	}
+	for _, n := range z.treeNodes() {
+		if nbEpi, _ := n.GetNumbers(); z.DoJunctions && nbEpi > 0 && !z.junctionCanceled(n) {
+			t.Junctions++
+			t.Splices += nbEpi
+			switch n.JunctionReadiness.State {
+			case node.ReadinessDone:
+				t.JunctionsDone++
+			case node.ReadinessReady:
+				t.JunctionsReady++
+			}
+		}
+		if nbFiber := n.GetToBeMeasuredFiber(); z.DoMeasurement && nbFiber > 0 {
+			t.Measurements++
+			t.Fibers += nbFiber
+			switch n.MeasurementReadiness.State {
+			case node.ReadinessDone:
+				t.MeasurementsDone++
+			case node.ReadinessReady:
+				t.MeasurementsReady++
+			}
+		}
+	}
+	return t
+}
+
+// UpdateReadiness computes the receiver zones nodes readiness (see Zone.UpdateReadiness)
+func (p *Project) UpdateReadiness() {
+	for _, pz := range p.Zones {
+		pz.Zone.UpdateReadiness()
+	}
+}
+
+// Totals returns the receiver zones totals (in project zones order), and the project overall totals. Zones readiness must be up to date
+// (see UpdateReadiness)
+func (p *Project) Totals() (zones []Totals, total Totals) {
+	for _, pz := range p.Zones {
+		t := pz.Zone.Totals()
+		zones = append(zones, t)
+		total.Add(t)
+	}
+	return
+}
+
+// WriteSummaryXLS writes project roll-up summary in <dir>/<project name>_synthese.xlsx : a Synthèse sheet with one row per zone
+// (Tirage, Racco and Mesures totals, progress and readiness) and a TOTAL row. Zones readiness must be up to date (see UpdateReadiness)
+func (p *Project) WriteSummaryXLS(dir string) error {
+	if len(p.Zones) == 0 {
+		return fmt.Errorf("project is empty, nothing to write to XLSx")
+	}
+	xlsx.SetDefaultFont(11, "Calibri")
+	xls := xlsx.NewFile()
+	sheet, err := xls.AddSheet("Synthèse")
+	if err != nil {
+		return err
+	}
+	cols := []col{
+		{"Zone", 20},
+		{"Nb PT", 10},
+		{"Tirage Nb Câbles", 12},
+		{"Tirage Longueur", 12},
+		{"Tirage Fait", 12},
+		{"Racco Nb Boitiers", 12},
+		{"Racco Nb Epissures", 12},
+		{"Racco Fait", 12},
+		{"Racco Prêt", 12},
+		{"Mesures Nb PT", 12},
+		{"Mesures Nb Fibres", 12},
+		{"Mesures Fait", 12},
+		{"Mesures Prêt", 12},
+	}
+	addHeaderRow(sheet, cols)
+	addTotals := func(name string, t Totals) *xlsx.Row {
+		r := sheet.AddRow()
+		r.AddCell().SetString(name)
+		for _, value := range []int{
+			t.Nodes,
+			t.Cables, t.CableLength, t.CablesDone,
+			t.Junctions, t.Splices, t.JunctionsDone, t.JunctionsReady,
+			t.Measurements, t.Fibers, t.MeasurementsDone, t.MeasurementsReady,
+		} {
+			r.AddCell().SetInt(value)
+		}
+		return r
+	}
+	zones, total := p.Totals()
+	for i, pz := range p.Zones {
+		addTotals(pz.Name, zones[i])
+	}
+	r := addTotals("TOTAL", total)
+	st := xlsx.NewStyle()
+	st.Fill = *xlsx.NewFill("solid", colorSummary, "00000000")
+	st.Font.Bold = true
+	st.ApplyFill = true
+	st.ApplyFont = true
+	for _, cell := range r.Cells {
+		cell.SetStyle(st)
+	}
+	return writeXLSFile(xls, filepath.Join(dir, p.Name+"_synthese.xlsx"))
+}
@@ -0,0 +1,82 @@
+package zone
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+	"github.com/tealeg/xlsx"
+)
+
+func TestProject(t *testing.T) {
+	p := NewProject("Commune")
+	z1, z2 := newTestPlanningZone(), newTestPlanningZone()
+	for _, c := range z2.Cables {
+		c.Status = &node.FieldStatus{Status: ripconst.StateDone}
+	}
+	z2.DoMeasurement = false
+	for _, pz := range []struct {
+		name string
+		zone *Zone
+	}{
+		{"PM 1", z1},
+		{"PM 2", z2},
+	} {
+		if err := p.Add(pz.name, pz.zone); err != nil {
+			t.Fatalf("Add returned unexpected: %s", err.Error())
+		}
+	}
+	if err := p.Add("PM 1", New()); err == nil {
+		t.Errorf("Add should fail on duplicate zone name")
+	}
+
+	// same PT names are kept separate per zone
+	p.Node("PM 2", "PT 1").JunctionStatus = &node.FieldStatus{Status: ripconst.StateDone}
+	if p.Node("PM 1", "PT 1").JunctionStatus != nil {
+		t.Errorf("PM 1 'PT 1' should not be altered by PM 2 one")
+	}
+
+	p.UpdateReadiness()
+	zones, total := p.Totals()
+	if len(zones) != 2 {
+		t.Fatalf("unexpected number of zone totals %d", len(zones))
+	}
+	for _, tc := range []struct {
+		name     string
+		got, exp int
+	}{
+		{"PM 1 cables done", zones[0].CablesDone, 0},
+		{"PM 2 cables done", zones[1].CablesDone, 2},
+		{"PM 1 junctions ready", zones[0].JunctionsReady, 0},
+		{"PM 2 junctions ready", zones[1].JunctionsReady, 1},
+		{"PM 2 junctions done", zones[1].JunctionsDone, 1},
+		{"PM 2 measurements", zones[1].Measurements, 0},
+		{"total cables", total.Cables, zones[0].Cables + zones[1].Cables},
+		{"total splices", total.Splices, zones[0].Splices + zones[1].Splices},
+		{"total fibers", total.Fibers, zones[0].Fibers},
+	} {
+		if tc.got != tc.exp {
+			t.Errorf("%s is %d instead of %d", tc.name, tc.got, tc.exp)
+		}
+	}
+	if zones[0].Splices != 110 || zones[0].Cables != 2 {
+		t.Errorf("unexpected PM 1 totals %+v", zones[0])
+	}
+
+	dir := t.TempDir()
+	if err := p.WriteSummaryXLS(dir); err != nil {
+		t.Fatalf("WriteSummaryXLS returned unexpected: %s", err.Error())
+	}
+	xls, err := xlsx.OpenFile(filepath.Join(dir, "Commune_synthese.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet := xls.Sheet["Synthèse"]
+	if sheet == nil || sheet.MaxRow != 4 {
+		t.Fatalf("unexpected Synthèse sheet")
+	}
+	if name := sheet.Cell(3, 0).Value; name != "TOTAL" {
+		t.Errorf("unexpected last row '%s'", name)
+	}
+}
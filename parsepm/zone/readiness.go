@@ -0,0 +1,133 @@
+package zone
+
+import (
+	"sort"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+)
+
+// statusReadiness returns the readiness of a work having given field status, if it is done or canceled (ok is false otherwise)
+func statusReadiness(fs *node.FieldStatus) (rd node.Readiness, ok bool) {
+	if fs == nil {
+		return node.Readiness{}, false
+	}
+	switch fs.Status {
+	case ripconst.StateDone:
+		return node.Readiness{State: node.ReadinessDone}, true
+	case ripconst.StateCanceled:
+		return node.Readiness{State: node.ReadinessCanceled}, true
+	}
+	return node.Readiness{}, false
+}
+
+// junctionCanceled returns true if given node junction is not to be done according to zone activities (ELINE or other junctions disabled),
+// and no field status was imported for it
+func (z *Zone) junctionCanceled(n *node.Node) bool {
+	if n.JunctionStatus != nil {
+		return false
+	}
+	return (!z.DoEline && n.BPEType == "ELINE") || (!z.DoOtherThanEline && n.BPEType != "ELINE")
+}
+
+// junctionTroncons returns the troncons whose pulling given node junction depends on : its incoming troncon, then its outgoing ones (sorted by name).
+//
+// This dependency rule is shared by UpdateReadiness and Plan
+func junctionTroncons(n *node.Node) []*node.Troncon {
+	res := []*node.Troncon{}
+	if n.TronconIn != nil {
+		res = append(res, n.TronconIn)
+	}
+	names := make([]string, 0, len(n.TronconsOut))
+	for name := range n.TronconsOut {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if tr := n.TronconsOut[name]; tr != nil && tr != n.TronconIn {
+			res = append(res, tr)
+		}
+	}
+	return res
+}
+
+// measurementJunctions returns the nodes whose junction given node measurement depends on : the node itself, then its SplicePT chain.
+//
+// This dependency rule is shared by UpdateReadiness and Plan
+func measurementJunctions(n *node.Node) []string {
+	res := []string{n.PtName}
+	for _, ptName := range n.SplicePT {
+		if ptName != n.PtName {
+			res = append(res, ptName)
+		}
+	}
+	return res
+}
+
+// UpdateReadiness computes zone tree nodes junction and measurement readiness from imported field statuses :
+//
+// - a junction is blocked until its incoming and outgoing troncons are pulled (if pulling activity is enabled and troncon belongs to a zone cable),
+//
+// - a measurement is blocked until its node and all nodes of its SplicePT chain are spliced (or their junction canceled).
+//
+// Disabled activities are considered as not to be done
+func (z *Zone) UpdateReadiness() {
+	// pulled troncons status, imported per troncon or per cable
+	pullings := map[*node.Troncon]*node.FieldStatus{}
+	if z.DoPulling {
+		for _, c := range z.Cables {
+			if c.CableType() == "" {
+				continue
+			}
+			for _, tr := range c.Troncons {
+				pullings[tr] = c.Status
+				if tr.PullingStatus != nil {
+					pullings[tr] = tr.PullingStatus
+				}
+			}
+		}
+	}
+
+	nodes := z.treeNodes()
+	for _, n := range nodes {
+		rd, finished := statusReadiness(n.JunctionStatus)
+		switch {
+		case finished:
+		case !z.DoJunctions || z.junctionCanceled(n):
+			rd = node.Readiness{State: node.ReadinessCanceled}
+		default:
+			rd = node.Readiness{State: node.ReadinessReady}
+			for _, tr := range junctionTroncons(n) {
+				fs, isPulled := pullings[tr]
+				if !isPulled {
+					continue
+				}
+				if prd, _ := statusReadiness(fs); !prd.IsFinished() {
+					rd.State = node.ReadinessBlocked
+					rd.BlockedBy = append(rd.BlockedBy, sheetTirage+" "+tr.Name)
+				}
+			}
+		}
+		n.JunctionReadiness = rd
+	}
+
+	for _, n := range nodes {
+		rd, finished := statusReadiness(n.MeasurementStatus)
+		switch {
+		case finished:
+		case !z.DoMeasurement:
+			rd = node.Readiness{State: node.ReadinessCanceled}
+		default:
+			rd = node.Readiness{State: node.ReadinessReady}
+			for _, ptName := range measurementJunctions(n) {
+				pt := z.Nodes[ptName]
+				if pt == nil || pt.JunctionReadiness.IsFinished() {
+					continue
+				}
+				rd.State = node.ReadinessBlocked
+				rd.BlockedBy = append(rd.BlockedBy, sheetRacco+" "+ptName)
+			}
+		}
+		n.MeasurementReadiness = rd
+	}
+}
@@ -0,0 +1,66 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+)
+
+func TestZone_UpdateReadiness(t *testing.T) {
+	z := newTestPlanningZone()
+	pt1, pt2 := z.Nodes["PT 1"], z.Nodes["PT 2"]
+	z.Cables[0].Status = &node.FieldStatus{Status: ripconst.StateDone}
+	pt1.JunctionStatus = &node.FieldStatus{Status: ripconst.StateDone}
+	z.UpdateReadiness()
+
+	for _, tc := range []struct {
+		name     string
+		rd       node.Readiness
+		expected string
+	}{
+		{"PT 1 junction", pt1.JunctionReadiness, node.ReadinessDone},
+		{"PT 2 junction", pt2.JunctionReadiness, "Bloqué : Tirage TR-B"},
+		{"PT 1 measurement", pt1.MeasurementReadiness, node.ReadinessReady},
+		{"PT 2 measurement", pt2.MeasurementReadiness, "Bloqué : Racco PT 2"},
+	} {
+		if tc.rd.String() != tc.expected {
+			t.Errorf("%s readiness is '%s' instead of '%s'", tc.name, tc.rd.String(), tc.expected)
+		}
+	}
+
+	// junction also waits for its outgoing troncons pulling
+	pt1.JunctionStatus = nil
+	z.UpdateReadiness()
+	if pt1.JunctionReadiness.String() != "Bloqué : Tirage TR-B" {
+		t.Errorf("PT 1 junction readiness is '%s' instead of waiting for TR-B pulling", pt1.JunctionReadiness.String())
+	}
+	pt1.JunctionStatus = &node.FieldStatus{Status: ripconst.StateDone}
+
+	// troncon pulling status prevails on cable one, and disabled activities do not block
+	z.Cables[1].Troncons[0].PullingStatus = &node.FieldStatus{Status: ripconst.StateDone}
+	z.DoJunctions = false
+	z.UpdateReadiness()
+	if pt2.JunctionReadiness.State != node.ReadinessCanceled || pt2.MeasurementReadiness.State != node.ReadinessReady {
+		t.Errorf("unexpected PT 2 readiness %v, %v", pt2.JunctionReadiness, pt2.MeasurementReadiness)
+	}
+	z.DoJunctions = true
+
+	xls, err := z.buildXLS()
+	if err != nil {
+		t.Fatalf("buildXLS returned unexpected: %s", err.Error())
+	}
+	sheet := xls.Sheet[sheetRacco]
+	found := false
+	for row := 1; row < sheet.MaxRow; row++ {
+		if sheet.Cell(row, colRaccoPtName).Value == "PT 2" && sheet.Cell(row, colRaccoOpe).Value == "TOTAL" {
+			found = true
+			if value := sheet.Cell(row, colRaccoStatus+nbFieldCols).Value; value != node.ReadinessReady {
+				t.Errorf("unexpected PT 2 Racco readiness '%s'", value)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("PT 2 Racco row not found")
+	}
+}
@@ -0,0 +1,198 @@
+package zone
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsemesure/measurement"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	checkNoCampaign      string = "Campagne absente"
+	checkUnknownCampaign string = "Campagne inconnue"
+	checkMissingFiber    string = "Fibre manquante"
+	checkDistance        string = "Distance mesurée"
+	checkSpliceLoss      string = "Perte épissure"
+	checkTotalLoss       string = "Perte totale"
+
+	distanceTolerance float64 = 0.1 // relative tolerance on measured distance (vs DistFromPM)
+	distanceMargin    float64 = 30  // minimum tolerance on measured distance (m)
+
+	wavelength1310 string = "1310"
+	wavelength1550 string = "1550"
+)
+
+// CampaignResult sums up the OTDR campaign(s) related to a measurement target node
+type CampaignResult struct {
+	Node        *node.Node
+	Campaigns   []string
+	NbFiber     int                // number of distinct measured fibers
+	MaxDistance float64            // m
+	MaxSplice   float64            // dB
+	MaxLoss     map[string]float64 // maximum total loss per wavelength (dB)
+}
+
+// normalizePtName returns given PT name without spaces and upper cased (campaign PT names are typed by technicians)
+func normalizePtName(ptName string) string {
+	return strings.ToUpper(strings.Replace(ptName, " ", "", -1))
+}
+
+// measurementTargets returns zone nodes to be measured (as listed in Mesures sheet), by normalized PT name
+func (z *Zone) measurementTargets() map[string]*node.Node {
+	res := map[string]*node.Node{}
+	for _, n := range z.allNodes() {
+		if n.GetToBeMeasuredFiber() > 0 {
+			res[normalizePtName(n.PtName)] = n
+		}
+	}
+	return res
+}
+
+// Reconcile matches given OTDR campaigns to zone measurement targets (by PT name), and returns per target results (sorted by PT name) along with found anomalies :
+// targets without campaign, missing fibers, distance mismatches, splice or total loss exceeding zone LossBudget, and campaigns not related to any target
+func (z *Zone) Reconcile(campaigns []measurement.Campaign) ([]*CampaignResult, Diagnostics) {
+	targets := z.measurementTargets()
+	results := map[*node.Node]*CampaignResult{}
+	diags := Diagnostics{}
+	fibers := map[*node.Node]map[string]bool{}
+
+	for _, c := range campaigns {
+		n := targets[normalizePtName(c.PtName)]
+		if n == nil {
+			diags = append(diags, Diagnostic{Source: checkUnknownCampaign, Severity: SeverityWarning, PtName: c.PtName, Msg: fmt.Sprintf("campaign '%s' is not related to any measurement target", c.Name)})
+			continue
+		}
+		res := results[n]
+		if res == nil {
+			res = &CampaignResult{Node: n, MaxLoss: map[string]float64{}}
+			results[n] = res
+			fibers[n] = map[string]bool{}
+		}
+		res.Campaigns = append(res.Campaigns, c.Name)
+		for _, m := range c.Measurements {
+			fibers[n][m.Name] = true
+			res.MaxDistance = math.Max(res.MaxDistance, m.Distance)
+			res.MaxSplice = math.Max(res.MaxSplice, m.MaxSplice)
+			for _, wl := range []string{wavelength1310, wavelength1550} {
+				if strings.Contains(m.Wavelength, wl) {
+					res.MaxLoss[wl] = math.Max(res.MaxLoss[wl], m.TotLoss)
+				}
+			}
+		}
+		res.NbFiber = len(fibers[n])
+	}
+
+	nodes := make([]*node.Node, 0, len(targets))
+	for _, n := range targets {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].PtName < nodes[j].PtName
+	})
+
+	resList := []*CampaignResult{}
+	for _, n := range nodes {
+		res := results[n]
+		if res == nil {
+			diags = append(diags, Diagnostic{Source: checkNoCampaign, Severity: SeverityError, PtName: n.PtName, Msg: "no measurement campaign found"})
+			resList = append(resList, &CampaignResult{Node: n, MaxLoss: map[string]float64{}})
+			continue
+		}
+		resList = append(resList, res)
+		diags = append(diags, z.checkCampaignResult(res)...)
+	}
+	return resList, diags
+}
+
+func (z *Zone) checkCampaignResult(res *CampaignResult) (diags Diagnostics) {
+	n := res.Node
+	if wf := n.GetToBeMeasuredFiber(); res.NbFiber < wf {
+		diags = append(diags, Diagnostic{Source: checkMissingFiber, Severity: SeverityError, PtName: n.PtName, Msg: fmt.Sprintf("%d measured fiber(s) instead of %d", res.NbFiber, wf)})
+	}
+	tolerance := math.Max(float64(n.DistFromPM)*distanceTolerance, distanceMargin)
+	if math.Abs(res.MaxDistance-float64(n.DistFromPM)) > tolerance {
+		diags = append(diags, Diagnostic{Source: checkDistance, Severity: SeverityWarning, PtName: n.PtName, Msg: fmt.Sprintf("measured distance %.0fm instead of %dm", res.MaxDistance, n.DistFromPM)})
+	}
+	if res.MaxSplice > z.LossBudget.SpliceLoss {
+		diags = append(diags, Diagnostic{Source: checkSpliceLoss, Severity: SeverityError, PtName: n.PtName, Msg: fmt.Sprintf("max splice loss %.2fdB exceeds %.2fdB", res.MaxSplice, z.LossBudget.SpliceLoss)})
+	}
+	loss1310, loss1550 := z.LossBudget.NodeMaxLoss(n)
+	for _, budget := range []struct {
+		wl      string
+		maxLoss float64
+	}{{wavelength1310, loss1310}, {wavelength1550, loss1550}} {
+		if loss, found := res.MaxLoss[budget.wl]; found && loss > budget.maxLoss {
+			diags = append(diags, Diagnostic{Source: checkTotalLoss, Severity: SeverityError, PtName: n.PtName, Msg: fmt.Sprintf("total loss %.2fdB at %s nm exceeds %.2fdB", loss, budget.wl, budget.maxLoss)})
+		}
+	}
+	return
+}
+
+// WriteReconcileXLS reconciles given OTDR campaigns with zone measurement targets, and writes the result in <dir>/<name>_recette.xlsx
+// (Synthèse sheet with one row per target, and Contrôles sheet listing anomalies). Anomalies are returned as Diagnostics
+func (z *Zone) WriteReconcileXLS(dir, name string, campaigns []measurement.Campaign) (Diagnostics, error) {
+	results, diags := z.Reconcile(campaigns)
+
+	xlsx.SetDefaultFont(11, "Calibri")
+	xls := xlsx.NewFile()
+	sheet, err := xls.AddSheet("Synthèse")
+	if err != nil {
+		return nil, err
+	}
+	cols := []col{
+		{"PT cible", 15},
+		{"Nb Fibres", 12},
+		{"Nb Fibres mesurées", 12},
+		{"Distance", 12},
+		{"Distance mesurée", 12},
+		{"Nb Episs.", 12},
+		{"Perte max épissure", 12},
+		{"Perte max 1310 (dB)", 12},
+		{"Perte mesurée 1310", 12},
+		{"Perte max 1550 (dB)", 12},
+		{"Perte mesurée 1550", 12},
+		{"Campagne(s)", 50},
+	}
+	addHeaderRow(sheet, cols)
+
+	hasAnomaly := map[string]bool{}
+	for _, d := range diags {
+		hasAnomaly[d.PtName] = true
+	}
+	for _, res := range results {
+		n := res.Node
+		loss1310, loss1550 := z.LossBudget.NodeMaxLoss(n)
+		r := sheet.AddRow()
+		r.AddCell().SetString(n.PtName)
+		r.AddCell().SetInt(n.GetToBeMeasuredFiber())
+		r.AddCell().SetInt(res.NbFiber)
+		r.AddCell().SetInt(n.DistFromPM)
+		r.AddCell().SetFloatWithFormat(res.MaxDistance, "0")
+		r.AddCell().SetInt(len(n.SplicePT))
+		r.AddCell().SetFloatWithFormat(res.MaxSplice, "0.00")
+		r.AddCell().SetFloatWithFormat(loss1310, "0.00")
+		r.AddCell().SetFloatWithFormat(res.MaxLoss[wavelength1310], "0.00")
+		r.AddCell().SetFloatWithFormat(loss1550, "0.00")
+		r.AddCell().SetFloatWithFormat(res.MaxLoss[wavelength1550], "0.00")
+		r.AddCell().SetString(strings.Join(res.Campaigns, ", "))
+
+		color := colorGreen
+		if hasAnomaly[n.PtName] {
+			color = colorRed
+		}
+		fillRow(r, color)
+	}
+
+	if len(diags) > 0 {
+		err = z.addControlesSheet(xls, diags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return diags, writeXLSFile(xls, filepath.Join(dir, name+"_recette.xlsx"))
+}
@@ -0,0 +1,49 @@
+package zone
+
+import (
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsemesure/measurement"
+)
+
+func TestZone_Reconcile(t *testing.T) {
+	z := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+	pt := z.Nodes["PT 1"]
+	if pt.GetToBeMeasuredFiber() != 1 {
+		t.Fatalf("PT 1 should have 1 fiber to be measured")
+	}
+
+	campaigns := []measurement.Campaign{
+		{Name: "C1", PtName: "PT1", Measurements: []measurement.Measurement{
+			{Name: "F1", Wavelength: "1310", TotLoss: 0.9, Distance: 125, MaxSplice: 0.1},
+			{Name: "F1", Wavelength: "1550", TotLoss: 5, Distance: 125, MaxSplice: 0.1},
+		}},
+		{Name: "C2", PtName: "PT 9"},
+	}
+	results, diags := z.Reconcile(campaigns)
+	expected := map[string]string{
+		checkUnknownCampaign: "PT 9",
+		checkTotalLoss:       "PT 1",
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("Reconcile returned %d anomalies instead of %d:\n%s", len(diags), len(expected), diags.Error())
+	}
+	for _, d := range diags {
+		if expected[d.Source] != d.PtName {
+			t.Errorf("unexpected anomaly %s", d.String())
+		}
+	}
+	if len(results) != 1 || results[0].Node != pt || results[0].NbFiber != 1 || results[0].MaxLoss[wavelength1550] != 5 {
+		t.Errorf("unexpected results %v", results)
+	}
+
+	_, diags = z.Reconcile(nil)
+	if len(diags) != 1 || diags[0].Source != checkNoCampaign {
+		t.Errorf("missing campaign should be reported:\n%s", diags.Error())
+	}
+
+	if _, err := z.WriteReconcileXLS(t.TempDir(), "test", campaigns); err != nil {
+		t.Fatalf("WriteReconcileXLS returned unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,156 @@
+package zone
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// RopLayout describes ROP workbook layout : sheet selection, columns position and service column rules
+//
+// Absolute columns (Col...) are sheet column numbers, block columns (Block...) are offsets relative to current PT block
+type RopLayout struct {
+	Name        string `json:"name"`
+	SheetPrefix string `json:"sheetPrefix"` // ROP sheet is the first one having name starting with SheetPrefix
+
+	ColPmName     int `json:"colPmName"`
+	ColDrawer     int `json:"colDrawer"`
+	ColDrawerLine int `json:"colDrawerLine"`
+	ColDrawerCol  int `json:"colDrawerCol"`
+	ColFirstChild int `json:"colFirstChild"`
+
+	BlockTubulure   int    `json:"blockTubulure"`
+	BlockFiber      int    `json:"blockFiber"` // fiber number, -1 if ROP has no fiber column (optical paths are then traced at route level only)
+	BlockCableIn    int    `json:"blockCableIn"`
+	BlockName       int    `json:"blockName"`
+	BlockPtName     int    `json:"blockPtName"`
+	BlockDistFromPM int    `json:"blockDistFromPM"`
+	BlockOpe        int    `json:"blockOpe"`
+	BlockNext       int    `json:"blockNext"`   // offset to next (child) block
+	BlockMarker     string `json:"blockMarker"` // header value (first row) flagging a PT block start
+
+	// Service column is the ServiceColOffset-th column after last PT block, and must have ServiceHeader as header value
+	ServiceHeader    string `json:"serviceHeader"`
+	ServiceColOffset int    `json:"serviceColOffset"`
+
+	// Route is used for client (resp. reserve) if its lower-cased service value starts with one of ClientServices (resp. ReserveServices)
+	ClientServices  []string `json:"clientServices"`
+	ReserveServices []string `json:"reserveServices"`
+}
+
+// standardRopLayout is the layout used by the ROP files handled so far (whatever the operator)
+var standardRopLayout = RopLayout{
+	Name:        "standard",
+	SheetPrefix: "TAB",
+
+	ColPmName:     0,
+	ColDrawer:     3,
+	ColDrawerLine: 4,
+	ColDrawerCol:  5,
+	ColFirstChild: 6,
+
+	BlockTubulure:   0,
+	BlockFiber:      -1,
+	BlockCableIn:    2,
+	BlockName:       4,
+	BlockPtName:     5,
+	BlockDistFromPM: 6,
+	BlockOpe:        7,
+	BlockNext:       8,
+	BlockMarker:     "T",
+
+	ServiceHeader:    "SERVICE",
+	ServiceColOffset: 2,
+
+	ClientServices:  []string{"client ftth"},
+	ReserveServices: []string{"reserve"},
+}
+
+// axiansRopLayout is the standard layout, with accented reserve service labels
+var axiansRopLayout = func() RopLayout {
+	layout := standardRopLayout.clone()
+	layout.Name = "axians"
+	layout.ReserveServices = []string{"reserve", "réserve"}
+	return layout
+}()
+
+// sogetrelRopLayout is the standard layout, restricted to SRO sheets
+var sogetrelRopLayout = func() RopLayout {
+	layout := standardRopLayout.clone()
+	layout.Name = "sogetrel"
+	layout.SheetPrefix = "TAB_SRO"
+	return layout
+}()
+
+// RopLayouts lists built-in layout profiles, by name (operator profiles only differ from standard one by sheet selector or service labels).
+// Files not matching these layouts are to be described by a JSON layout file
+var RopLayouts = map[string]RopLayout{
+	standardRopLayout.Name: standardRopLayout,
+	axiansRopLayout.Name:   axiansRopLayout,
+	sogetrelRopLayout.Name: sogetrelRopLayout,
+}
+
+// clone returns a copy of the receiver layout, not sharing its service lists
+func (rl RopLayout) clone() RopLayout {
+	rl.ClientServices = append([]string{}, rl.ClientServices...)
+	rl.ReserveServices = append([]string{}, rl.ReserveServices...)
+	return rl
+}
+
+// DefaultRopLayout returns the default ROP layout profile
+func DefaultRopLayout() RopLayout {
+	return standardRopLayout.clone()
+}
+
+// GetRopLayout returns the built-in layout profile having given name, or loads it from given file if name is a JSON file path.
+//
+// Fields missing in a layout file keep their default value
+func GetRopLayout(name string) (RopLayout, error) {
+	if name == "" {
+		return DefaultRopLayout(), nil
+	}
+	if layout, found := RopLayouts[strings.ToLower(name)]; found {
+		return layout.clone(), nil
+	}
+	if strings.ToLower(filepath.Ext(name)) != ".json" {
+		return RopLayout{}, fmt.Errorf("unknown ROP layout '%s'", name)
+	}
+	content, err := ioutil.ReadFile(name)
+	if err != nil {
+		return RopLayout{}, err
+	}
+	layout := DefaultRopLayout()
+	layout.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	err = json.Unmarshal(content, &layout)
+	if err != nil {
+		return RopLayout{}, fmt.Errorf("could not parse ROP layout file '%s': %s", filepath.Base(name), err.Error())
+	}
+	return layout, nil
+}
+
+// IsRopSheet returns true if given sheet name matches layout sheet selector
+func (rl RopLayout) IsRopSheet(name string) bool {
+	return strings.HasPrefix(name, rl.SheetPrefix)
+}
+
+// IsClientService returns true if given service value designates a client route
+func (rl RopLayout) IsClientService(service string) bool {
+	return matchService(service, rl.ClientServices)
+}
+
+// IsReserveService returns true if given service value designates a reserve route
+func (rl RopLayout) IsReserveService(service string) bool {
+	return matchService(service, rl.ReserveServices)
+}
+
+func matchService(service string, prefixes []string) bool {
+	service = strings.ToLower(strings.Trim(service, " "))
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(service, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
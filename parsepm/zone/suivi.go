@@ -0,0 +1,224 @@
+package zone
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/doe/website/backend/model/ripsites"
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	sheetTirage  string = "Tirage"
+	sheetRacco   string = "Racco"
+	sheetMesures string = "Mesures"
+
+	colTirageCableType   int = 0
+	colTirageTronconName int = 1
+	colTirageStatus      int = 11
+	colRaccoPtName       int = 0
+	colRaccoOpe          int = 7
+	colRaccoStatus       int = 10
+	colMesuresPtName     int = 0
+	colMesuresNbFiber    int = 1
+	colMesuresStatus     int = 6
+
+	// field columns offset, relative to Statut column
+	offFieldActors int = 1
+	offFieldTrip   int = 2
+	offFieldBegin  int = 3
+	offFieldEnd    int = 4
+)
+
+// ParseSuiviXLS reads field teams progress info from given suivi workbook (as generated by WriteXLS),
+// and merges it on zone Cables and Troncons (Tirage sheet), and Nodes (Racco and Mesures sheets).
+//
+// Rows which can not be related to zone items, and unknown status values are returned as Diagnostics
+func (z *Zone) ParseSuiviXLS(file string) (Diagnostics, error) {
+	xf, err := xlsx.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+	sp := &suiviParser{zone: z, source: filepath.Base(file), nodes: z.allNodes()}
+	if sh := xf.Sheet[sheetTirage]; sh != nil {
+		sp.parseTirage(sh)
+	}
+	if sh := xf.Sheet[sheetRacco]; sh != nil {
+		sp.parseRacco(sh)
+	}
+	if sh := xf.Sheet[sheetMesures]; sh != nil {
+		sp.parseMesures(sh)
+	}
+	return sp.diags, nil
+}
+
+// allNodes returns all zone nodes (including PM nodes created while building zone tree) by PtName
+func (z *Zone) allNodes() node.Nodes {
+	res := node.NewNodes()
+	var visit func(n *node.Node)
+	visit = func(n *node.Node) {
+		if !res.Add(n) {
+			return
+		}
+		for _, cn := range n.Children {
+			visit(cn)
+		}
+	}
+	for _, root := range z.roots() {
+		visit(root)
+	}
+	for _, n := range z.Nodes {
+		res.Add(n)
+	}
+	return res
+}
+
+type suiviParser struct {
+	zone   *Zone
+	source string
+	nodes  node.Nodes
+	diags  Diagnostics
+}
+
+func (sp *suiviParser) report(sh *xlsx.Sheet, row, col int, sev Severity, ptName, troncon, msg string) {
+	sp.diags = append(sp.diags, Diagnostic{
+		Source:   sp.source,
+		Cell:     sh.Name + "!" + xlsx.GetCellIDStringFromCoords(col, row),
+		Severity: sev,
+		PtName:   ptName,
+		Troncon:  troncon,
+		Msg:      msg,
+	})
+}
+
+// fieldStatus returns field status found on given row (nil if no field info is defined)
+func (sp *suiviParser) fieldStatus(sh *xlsx.Sheet, row, colStatus int) *node.FieldStatus {
+	fs := &node.FieldStatus{
+		Label:  strings.TrimSpace(sh.Cell(row, colStatus).Value),
+		Actors: strings.TrimSpace(sh.Cell(row, colStatus+offFieldActors).Value),
+		Trip:   strings.TrimSpace(sh.Cell(row, colStatus+offFieldTrip).Value),
+		Begin:  cellDate(sh.Cell(row, colStatus+offFieldBegin)),
+		End:    cellDate(sh.Cell(row, colStatus+offFieldEnd)),
+	}
+	if fs.IsEmpty() {
+		return nil
+	}
+	status, known := parseFieldStatus(fs.Label)
+	if !known {
+		sp.report(sh, row, colStatus, SeverityError, "", "", fmt.Sprintf("unknown status '%s' (considered as to do)", fs.Label))
+	}
+	fs.Status = status
+	return fs
+}
+
+// parseFieldStatus returns ripsite state related to given Statut cell value.
+//
+// Vocabulary extends parsesuivi one ('a faire', 'fait' and 'bloque' synonyms), and tells in progress ('en cours') and blocked routes apart
+// where parsesuivi considers them as to do
+func parseFieldStatus(label string) (status string, known bool) {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "", "a faire":
+		return ripconst.StateToDo, true
+	case "ok", "fait":
+		return ripconst.StateDone, true
+	case "en cours":
+		return ripconst.StateInProgress, true
+	case "nok", "ko", "blocage", "bloque":
+		return ripconst.StateBlocked, true
+	case "na", "annule", "supprime", "suprime":
+		return ripconst.StateCanceled, true
+	}
+	return ripconst.StateToDo, false
+}
+
+// cellDate returns cell value, formatted as YYYY-MM-DD if cell holds a date
+func cellDate(cell *xlsx.Cell) string {
+	if cell.Value == "" {
+		return ""
+	}
+	if t, err := cell.GetTime(false); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return strings.TrimSpace(cell.Value)
+}
+
+func (sp *suiviParser) parseTirage(sh *xlsx.Sheet) {
+	cables := map[string]*node.Cable{}
+	for _, cable := range sp.zone.Cables {
+		cables[cable.Troncons[0].Name] = cable
+	}
+	var cable *node.Cable
+	for row := 1; row < sh.MaxRow; row++ {
+		cableType := sh.Cell(row, colTirageCableType).Value
+		trName := sh.Cell(row, colTirageTronconName).Value
+		switch {
+		case cableType != "" && trName != "":
+			// cable declaration row
+			cable = cables[trName]
+			if cable == nil {
+				sp.report(sh, row, colTirageTronconName, SeverityWarning, "", trName, "unknown cable. Skipping")
+				continue
+			}
+			cable.Status = sp.fieldStatus(sh, row, colTirageStatus)
+		case cableType == "" && trName != "":
+			// cable troncon row
+			tr := sp.zone.Troncons[trName]
+			if tr == nil {
+				sp.report(sh, row, colTirageTronconName, SeverityWarning, "", trName, "unknown troncon. Skipping")
+				continue
+			}
+			tr.PullingStatus = sp.fieldStatus(sh, row, colTirageStatus)
+			if tr.PullingStatus == nil && cable != nil {
+				tr.PullingStatus = cable.Status
+			}
+		}
+	}
+}
+
+func (sp *suiviParser) parseRacco(sh *xlsx.Sheet) {
+	for row := 1; row < sh.MaxRow; row++ {
+		if sh.Cell(row, colRaccoOpe).Value != "TOTAL" {
+			continue
+		}
+		ptName := sh.Cell(row, colRaccoPtName).Value
+		n := sp.nodes[ptName]
+		if n == nil {
+			sp.report(sh, row, colRaccoPtName, SeverityWarning, ptName, "", "unknown node. Skipping")
+			continue
+		}
+		n.JunctionStatus = sp.fieldStatus(sh, row, colRaccoStatus)
+	}
+}
+
+func (sp *suiviParser) parseMesures(sh *xlsx.Sheet) {
+	for row := 1; row < sh.MaxRow; row++ {
+		ptName := sh.Cell(row, colMesuresPtName).Value
+		if ptName == "" || sh.Cell(row, colMesuresNbFiber).Value == "" {
+			continue
+		}
+		n := sp.nodes[ptName]
+		if n == nil {
+			sp.report(sh, row, colMesuresPtName, SeverityWarning, ptName, "", "unknown node. Skipping")
+			continue
+		}
+		n.MeasurementStatus = sp.fieldStatus(sh, row, colMesuresStatus)
+	}
+}
+
+// siteState returns the ripsite state related to given field status (defaultStatus state if no field status defined)
+func siteState(fs *node.FieldStatus, defaultStatus string) ripsites.State {
+	if fs == nil {
+		return ripsites.MakeState(defaultStatus)
+	}
+	state := ripsites.MakeState(fs.Status)
+	state.Team = fs.Actors
+	state.DateStart = fs.Begin
+	state.DateEnd = fs.End
+	if fs.Trip != "" {
+		state.Comment = "Déplacement " + fs.Trip
+	}
+	return state
+}
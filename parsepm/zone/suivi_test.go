@@ -0,0 +1,56 @@
+package zone
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
+	"github.com/tealeg/xlsx"
+)
+
+func TestZone_ParseSuiviXLS(t *testing.T) {
+	z := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+	dir := t.TempDir()
+	if err := z.WriteXLS(dir, "test"); err != nil {
+		t.Fatalf("WriteXLS returned unexpected: %s", err.Error())
+	}
+
+	// simulate field teams input
+	file := filepath.Join(dir, "test_suivi.xlsx")
+	xf, err := xlsx.OpenFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	racco := xf.Sheet[sheetRacco]
+	for row := 1; row < racco.MaxRow; row++ {
+		if racco.Cell(row, colRaccoPtName).Value == "PT 1" {
+			racco.Cell(row, colRaccoStatus).SetString("OK")
+			racco.Cell(row, colRaccoStatus+offFieldActors).SetString("Team A")
+		}
+	}
+	mesures := xf.Sheet[sheetMesures]
+	mesures.Cell(1, colMesuresStatus).SetString("blocage")
+	mesures.Cell(1, colMesuresStatus+offFieldTrip).SetString("12")
+	if err := xf.Save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	diags, err := z.ParseSuiviXLS(file)
+	if err != nil {
+		t.Fatalf("ParseSuiviXLS returned unexpected: %s", err.Error())
+	}
+	if len(diags) > 0 {
+		t.Errorf("ParseSuiviXLS returned unexpected diagnostics:\n%s", diags.Error())
+	}
+	pt := z.Nodes["PT 1"]
+	if pt.JunctionStatus == nil || pt.JunctionStatus.Status != ripconst.StateDone || pt.JunctionStatus.Actors != "Team A" {
+		t.Errorf("unexpected junction status %+v", pt.JunctionStatus)
+	}
+	if pt.MeasurementStatus == nil || pt.MeasurementStatus.Status != ripconst.StateBlocked || pt.MeasurementStatus.Trip != "12" {
+		t.Errorf("unexpected measurement status %+v", pt.MeasurementStatus)
+	}
+	if z.Sro.JunctionStatus != nil {
+		t.Errorf("SRO junction status should not be defined")
+	}
+}
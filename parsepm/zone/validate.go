@@ -0,0 +1,203 @@
+package zone
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	checkOrphanTroncon   string = "Tronçon orphelin"
+	checkUnreachableNode string = "Noeud non rattaché"
+	checkCapacity        string = "Capacité"
+	checkPassage         string = "Passage"
+)
+
+// report records the given inconsistency in zone Diagnostics (and prints it)
+func (z *Zone) report(source, cell string, sev Severity, ptName, troncon, msg string) {
+	d := Diagnostic{
+		Source:   source,
+		Cell:     cell,
+		Severity: sev,
+		PtName:   ptName,
+		Troncon:  troncon,
+		Msg:      msg,
+	}
+	fmt.Printf("\t%s\n", d.String())
+	z.Diagnostics = append(z.Diagnostics, d)
+}
+
+// Validate returns all inconsistencies found while parsing zone files, completed with zone structure checks
+// (orphan troncons, nodes unreachable from SRO, children capacity exceeding TronconIn's one, passage without outgoing troncon)
+func (z *Zone) Validate() Diagnostics {
+	res := append(Diagnostics{}, z.Diagnostics...)
+	res = append(res, z.checkOrphanTroncons()...)
+	res = append(res, z.checkUnreachableNodes()...)
+	res = append(res, z.checkCapacities()...)
+	res = append(res, z.checkPassages()...)
+	return res
+}
+
+func (z *Zone) sortedNodes() []*node.Node {
+	res := make([]*node.Node, 0, len(z.Nodes))
+	for _, n := range z.Nodes {
+		res = append(res, n)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].PtName < res[j].PtName
+	})
+	return res
+}
+
+func (z *Zone) sortedTroncons() []*node.Troncon {
+	res := make([]*node.Troncon, 0, len(z.Troncons))
+	for _, tr := range z.Troncons {
+		res = append(res, tr)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// roots returns zone top nodes (SRO if populated from ROP file, NodeRoots otherwise)
+func (z *Zone) roots() []*node.Node {
+	if len(z.Sro.Children) > 0 {
+		return []*node.Node{z.Sro}
+	}
+	return z.NodeRoots
+}
+
+func (z *Zone) checkOrphanTroncons() (res Diagnostics) {
+	for _, tr := range z.sortedTroncons() {
+		switch {
+		case tr.NodeSource == nil && tr.NodeDest == nil:
+			res = append(res, Diagnostic{Source: checkOrphanTroncon, Severity: SeverityError, Troncon: tr.Name, Msg: "troncon has neither source nor destination node"})
+		case tr.NodeSource == nil:
+			res = append(res, Diagnostic{Source: checkOrphanTroncon, Severity: SeverityError, PtName: tr.NodeDest.PtName, Troncon: tr.Name, Msg: "troncon has no source node"})
+		case tr.NodeDest == nil:
+			res = append(res, Diagnostic{Source: checkOrphanTroncon, Severity: SeverityError, PtName: tr.NodeSource.PtName, Troncon: tr.Name, Msg: "troncon has no destination node"})
+		}
+	}
+	return
+}
+
+func (z *Zone) checkUnreachableNodes() (res Diagnostics) {
+	reached := map[*node.Node]bool{}
+	var visit func(n *node.Node)
+	visit = func(n *node.Node) {
+		if reached[n] {
+			return
+		}
+		reached[n] = true
+		for _, cn := range n.Children {
+			visit(cn)
+		}
+	}
+	for _, root := range z.roots() {
+		visit(root)
+	}
+	for _, n := range z.sortedNodes() {
+		if !reached[n] {
+			res = append(res, Diagnostic{Source: checkUnreachableNode, Severity: SeverityError, PtName: n.PtName, Msg: "node is not reachable from SRO"})
+		}
+	}
+	return
+}
+
+func (z *Zone) checkCapacities() (res Diagnostics) {
+	for _, n := range z.sortedNodes() {
+		// PM and SRO TronconIn capacities are computed from their children
+		if n.LocationType == "PM" || n == z.Sro || n.TronconIn == nil || n.TronconIn.Capa == 0 {
+			continue
+		}
+		childrenCapa := 0
+		for _, cn := range n.Children {
+			if cn.TronconIn != nil {
+				childrenCapa += cn.TronconIn.Capa
+			}
+		}
+		if childrenCapa > n.TronconIn.Capa {
+			res = append(res, Diagnostic{
+				Source:   checkCapacity,
+				Severity: SeverityError,
+				PtName:   n.PtName,
+				Troncon:  n.TronconIn.Name,
+				Msg:      fmt.Sprintf("children troncons capacity (%dFO) exceeds troncon In capacity (%s)", childrenCapa, n.TronconIn.CapaString()),
+			})
+		}
+	}
+	return
+}
+
+func (z *Zone) checkPassages() (res Diagnostics) {
+	for _, n := range z.sortedNodes() {
+		for _, ope := range n.Operations() {
+			if node.OperationKeyType(ope) != opvocab.OpPassage {
+				continue
+			}
+			trName := node.OperationKeyTroncon(ope)
+			tr, found := n.TronconsOut[trName]
+			if trName == "" || !found || tr.NodeDest == nil {
+				res = append(res, Diagnostic{Source: checkPassage, Severity: SeverityError, PtName: n.PtName, Troncon: trName, Msg: "passage without outgoing troncon"})
+			}
+		}
+	}
+	return
+}
+
+func (z *Zone) addControlesSheet(xls *xlsx.File, controls Diagnostics) error {
+	sheet, err := xls.AddSheet("Contrôles")
+	if err != nil {
+		return err
+	}
+
+	cols := []col{
+		{"Source", 30},
+		{"Cellule", 10},
+		{"Gravité", 10},
+		{"PT", 15},
+		{"Tronçon", 20},
+		{"Message", 80},
+	}
+	addHeaderRow(sheet, cols)
+
+	for _, d := range controls {
+		r := sheet.AddRow()
+		r.AddCell().SetString(d.Source)
+		r.AddCell().SetString(d.Cell)
+		r.AddCell().SetString(d.Severity.String())
+		r.AddCell().SetString(d.PtName)
+		r.AddCell().SetString(d.Troncon)
+		r.AddCell().SetString(d.Msg)
+
+		if d.Severity == SeverityWarning {
+			continue
+		}
+		st := xlsx.NewStyle()
+		st.Font = *xlsx.NewFont(11, "Calibri")
+		st.Font.Color = "FFFF0000"
+		st.ApplyFont = true
+		for _, c := range r.Cells {
+			c.SetStyle(st)
+		}
+	}
+	return nil
+}
+
+// WriteControlsJSON writes zone Validate result as a JSON list in <dir>/<name>_controles.json
+func (z *Zone) WriteControlsJSON(dir, name string) error {
+	file := filepath.Join(dir, name+"_controles.json")
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("could not create file:%s\n", err.Error())
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(z.Validate())
+}
@@ -0,0 +1,25 @@
+package zone
+
+import "testing"
+
+func TestZone_Validate(t *testing.T) {
+	z := New()
+	NewRopParser(newTestRopSheet(t, "SERVICE", "120"), z).ParseRop()
+	if controls := z.Validate(); len(controls) > 0 {
+		t.Fatalf("Validate returned unexpected controls:\n%s", controls.Error())
+	}
+
+	pt := z.Nodes["PT 1"]
+	pt.TronconIn.Capa = 12
+	orphan := z.Troncons.Get("CABLE 2")
+	orphan.NodeSource = pt
+	pt.Operation["Passage->CABLE 2"] = 12
+	pt.TronconsOut.Add(orphan)
+	controls := z.Validate()
+	if len(controls) != 2 {
+		t.Fatalf("Validate returned %d controls instead of 2:\n%s", len(controls), controls.Error())
+	}
+	if controls[0].Source != checkOrphanTroncon || controls[1].Source != checkPassage {
+		t.Errorf("unexpected controls:\n%s", controls.Error())
+	}
+}
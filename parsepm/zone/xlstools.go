@@ -0,0 +1,38 @@
+package zone
+
+import "github.com/tealeg/xlsx"
+
+// Fill colors of output workbooks rows
+const (
+	colorGreen  string = "ffdfedda"
+	colorRed    string = "fffde9d9"
+	colorBlue   string = "ffb7dee8"
+	colorOrange string = "fffce4d6"
+
+	colorSummary string = "ffd9d9d9" // TOTAL rows
+)
+
+// col is an output sheet column : header title and width
+type col struct {
+	title string
+	width float64
+}
+
+// addHeaderRow adds given columns header row to given sheet, and sets columns width
+func addHeaderRow(xs *xlsx.Sheet, cols []col) {
+	r := xs.AddRow()
+	for i, c := range cols {
+		r.AddCell().SetString(c.title)
+		xs.Col(i).Width = c.width
+	}
+}
+
+// fillRow fills given row cells with given color
+func fillRow(r *xlsx.Row, color string) {
+	st := xlsx.NewStyle()
+	st.Fill = *xlsx.NewFill("solid", color, "00000000")
+	st.ApplyFill = true
+	for _, cell := range r.Cells {
+		cell.SetStyle(st)
+	}
+}
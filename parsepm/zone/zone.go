@@ -2,12 +2,20 @@ package zone
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/lpuig/ewin/chantiersalsace/dirbrowser"
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
 	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/lpuig/ewin/chantiersalsace/xlsreader"
 	"github.com/lpuig/ewin/doe/website/backend/model/date"
 	"github.com/lpuig/ewin/doe/website/backend/model/ripsites"
 	"github.com/lpuig/ewin/doe/website/frontend/model/ripsite/ripconst"
@@ -27,7 +35,21 @@ type Zone struct {
 	DoMeasurement       bool
 	CreateNodeFromRop   bool
 	DefineNodeOperation map[string]bool
-	BlobPattern         string
+	BlobPattern         string   // splice plan file glob pattern (used if BPEIncludes is empty)
+	BPEIncludes         []string // splice plan file glob patterns
+	BPEExcludes         []string // glob patterns of files to be ignored among BPEIncludes matching ones
+	BPERecursive        bool     // if true, BPEIncludes patterns without '/' match files in sub dirs too (top dir files only otherwise)
+	StrictRop           bool
+	RopLayout           RopLayout
+	BPELayouts          []node.BPELayout   // splice plan templates to detect (built-in ones if empty)
+	Vocabulary          opvocab.Vocabulary // operation labels synonyms, used by BPE and ROP parsers
+	BPEWorkers          int                // max number of splice plan files parsed concurrently (number of CPUs if 0)
+	Diagnostics         Diagnostics        // inconsistencies found while parsing zone files
+	Paths               []*OpticalPath     // fiber routes from PM drawers to attentes, as read in ROP file
+	LossBudget          node.LossBudget    // used to compute measurements maximum acceptable loss
+	MaterialTable       MaterialTable      // reference table used to derive the bill of materials
+	Productivity        Productivity       // field teams throughput, used to plan interventions
+	Interventions       []*Intervention    // planned field interventions (see Plan)
 }
 
 func New() *Zone {
@@ -38,7 +60,11 @@ func New() *Zone {
 		Sro:                 node.NewNode(),
 		CreateNodeFromRop:   true,
 		DefineNodeOperation: make(map[string]bool),
-		BlobPattern:         blobpattern_EasyFibre,
+		BlobPattern:         Blobpattern_EasyFibre,
+		RopLayout:           DefaultRopLayout(),
+		Vocabulary:          opvocab.DefaultVocabulary(),
+		LossBudget:          node.DefaultLossBudget(),
+		Productivity:        DefaultProductivity(),
 	}
 	z.Sro.Name = "SRO"
 	z.Sro.PtName = "SRO"
@@ -48,10 +74,17 @@ func New() *Zone {
 }
 
 const (
-	blobpattern_EasyFibre string = `*PT*.xlsx`
-	Blobpattern_Sogetrel  string = `*/_*.xlsx`
+	Blobpattern_EasyFibre string = `*PT*.xls*`
+	Blobpattern_Sogetrel  string = `*/_*.xls*`
 )
 
+// ParseBPEDir parses all BPE splice plan files found in given dir (and its sub dirs), and adds related nodes to the zone.
+//
+// Workbooks are selected by BPEIncludes patterns (BlobPattern if none defined) minus BPEExcludes ones, and by content : those matching patterns
+// but having no splice plan sheet matching a BPE layout are skipped, and reported as zone Diagnostics (workbooks not matching patterns and parsepm
+// output files are silently ignored).
+//
+// Files are parsed concurrently (using at most BPEWorkers goroutines) and merged in file path order. All files are processed : returned error lists all failing ones
 func (z *Zone) ParseBPEDir(dir string) error {
 	fs, err := os.Stat(dir)
 	if err != nil {
@@ -60,57 +93,230 @@ func (z *Zone) ParseBPEDir(dir string) error {
 	if !fs.IsDir() {
 		return fmt.Errorf("'%s' is not a directory", dir)
 	}
-	parseBlobPattern := filepath.Join(dir, z.BlobPattern)
-	files, err := filepath.Glob(parseBlobPattern)
+	bpeFiles, err := z.findBPEFiles(dir)
 	if err != nil {
 		return err
 	}
-	for _, f := range files {
-		// skip XLS temp files
-		if strings.HasPrefix(filepath.Base(f), "~") {
+
+	errs := []string{}
+	for i, res := range z.parseBPEFiles(bpeFiles) {
+		f := bpeFiles[i]
+		if errors.Is(res.err, node.ErrNoBPELayout) || errors.Is(res.err, node.ErrBPEHeader) {
+			z.report(relPath(dir, f), "", SeverityWarning, "", "", fmt.Sprintf("skipped : %s", res.err.Error()))
 			continue
 		}
-		n := node.NewNode()
-		err := n.ParseBPEXLS(f, z.Troncons)
-		if err != nil {
-			return fmt.Errorf("parsing '%s' returned error : %s\n", filepath.Base(f), err.Error())
+		if res.err != nil {
+			errs = append(errs, fmt.Sprintf("parsing '%s' returned error : %s", filepath.Base(f), res.err.Error()))
+			continue
 		}
+		n := res.node
 		fmt.Printf("'%s' parsed from %s\n", n.PtName, f)
 		newNode := z.Nodes.Add(n)
 		if !newNode {
-			return fmt.Errorf("node %s was already defined", n.PtName)
+			errs = append(errs, fmt.Sprintf("node %s (from '%s') was already defined", n.PtName, filepath.Base(f)))
+			continue
+		}
+		z.Troncons.Merge(n, res.troncons)
+		labels := []string{}
+		for label := range n.UnknownOperations {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			z.report(relPath(dir, f), "", SeverityWarning, n.PtName, "", fmt.Sprintf("unknown operation '%s' (%d fibers) : not counted", label, n.UnknownOperations[label]))
 		}
 	}
-
+	if len(errs) > 0 {
+		return fmt.Errorf("%d BPE file(s) in error :\n\t%s", len(errs), strings.Join(errs, "\n\t"))
+	}
 	return nil
 }
 
+// outputSuffixes lists parsepm output workbooks name suffixes (never parsed as splice plans)
+var outputSuffixes = []string{"_suivi", "_diff", "_tourets", "_materiel", "_recette", "_synthese"}
+
+// findBPEFiles returns the workbooks (.xlsx, or legacy .xls) found in dir matching zone include and exclude patterns. Sub dirs files are only
+// matched by patterns containing a '/', unless zone BPERecursive is set.
+//
+// Workbooks not matching include patterns and parsepm output workbooks are ignored. Temporary and excluded ones, and legacy .xls ones already
+// converted to .xlsx, are reported as skipped
+func (z *Zone) findBPEFiles(dir string) ([]string, error) {
+	includes := z.BPEIncludes
+	if len(includes) == 0 {
+		includes = []string{z.BlobPattern}
+	}
+	files := []string{}
+	err := dirbrowser.ProcessExts(dir, []string{".xlsx", ".xls"}, func(path string) error {
+		rel := relPath(dir, path)
+		pattern, found := matchPatterns(includes, rel)
+		if !found || (!z.BPERecursive && strings.Contains(rel, "/") && !strings.Contains(pattern, "/")) || isOutputFile(rel) {
+			return nil
+		}
+		if strings.HasPrefix(filepath.Base(path), "~") {
+			z.report(rel, "", SeverityWarning, "", "", "skipped : temporary file")
+			return nil
+		}
+		if exclude, found := matchPatterns(z.BPEExcludes, rel); found {
+			z.report(rel, "", SeverityWarning, "", "", fmt.Sprintf("skipped : matching BPE pattern '%s' but excluded by '%s'", pattern, exclude))
+			return nil
+		}
+		if converted := convertedFile(path); converted != "" {
+			z.report(rel, "", SeverityWarning, "", "", fmt.Sprintf("skipped : converted workbook '%s' is parsed instead", filepath.Base(converted)))
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// convertedFile returns the .xlsx workbook converted from given legacy .xls one (see xls2xlsx), if it exists next to it
+func convertedFile(file string) string {
+	ext := filepath.Ext(file)
+	if strings.ToLower(ext) != ".xls" {
+		return ""
+	}
+	converted := strings.TrimSuffix(file, ext) + ".xlsx"
+	if _, err := os.Stat(converted); err != nil {
+		return ""
+	}
+	return converted
+}
+
+// isOutputFile returns true if given file is a parsepm output workbook
+func isOutputFile(file string) bool {
+	name := strings.ToLower(strings.TrimSuffix(path.Base(file), path.Ext(file)))
+	for _, suffix := range outputSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPatterns returns the first given glob pattern matching relative file path (slash separated), if any.
+//
+// Patterns containing a '/' are matched against the whole relative path, others against the file name only (whatever its sub dir)
+func matchPatterns(patterns []string, rel string) (string, bool) {
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(strings.TrimSpace(pattern))
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if match, _ := path.Match(pattern, name); match {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// relPath returns file path relative to dir, slash separated (file path if it is not in dir)
+func relPath(dir, file string) string {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// bpeResult is the outcome of a splice plan file parsing : node and its troncons, or parsing error
+type bpeResult struct {
+	node     *node.Node
+	troncons node.Troncons
+	err      error
+}
+
+// parseBPEFiles parses given splice plan files using a pool of BPEWorkers goroutines, and returns their results in files order.
+//
+// Each file is parsed with its own Troncons, so that no zone data is shared between workers
+func (z *Zone) parseBPEFiles(files []string) []bpeResult {
+	results := make([]bpeResult, len(files))
+	nbWorkers := z.BPEWorkers
+	if nbWorkers <= 0 {
+		nbWorkers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < nbWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				n := node.NewNode()
+				troncons := node.NewTroncons()
+				err := n.ParseBPEXLS(files[i], troncons, z.Vocabulary, z.BPELayouts...)
+				results[i] = bpeResult{node: n, troncons: troncons, err: err}
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
 func (z *Zone) WriteXLS(dir, name string) error {
+	xls, err := z.buildXLS()
+	if err != nil {
+		return err
+	}
+	return writeXLSFile(xls, suiviFile(dir, name))
+}
+
+func suiviFile(dir, name string) string {
+	return filepath.Join(dir, name+"_suivi.xlsx")
+}
+
+// buildXLS returns suivi workbook with Tirage, Racco, Mesures (and Planning, Contrôles) sheets
+func (z *Zone) buildXLS() (*xlsx.File, error) {
 	if len(z.Nodes) == 0 {
-		return fmt.Errorf("zone is empty, nothing to write to XLSx")
+		return nil, fmt.Errorf("zone is empty, nothing to write to XLSx")
 	}
-	file := filepath.Join(dir, name+"_suivi.xlsx")
 
 	xlsx.SetDefaultFont(11, "Calibri")
 	xls := xlsx.NewFile()
+	z.UpdateReadiness()
 
 	if len(z.Cables) > 0 && z.Cables[0].Troncons[0].CableType != "" {
 		err := z.addTirageSheet(xls)
 		if err != nil {
-			return fmt.Errorf("Tirage : %s", err.Error())
+			return nil, fmt.Errorf("Tirage : %s", err.Error())
 		}
 	}
 
 	err := z.addRaccoSheet(xls)
 	if err != nil {
-		return fmt.Errorf("Racco : %s", err.Error())
+		return nil, fmt.Errorf("Racco : %s", err.Error())
 	}
 
 	err = z.addMesuresSheet(xls)
 	if err != nil {
-		return fmt.Errorf("Mesures : %s", err.Error())
+		return nil, fmt.Errorf("Mesures : %s", err.Error())
 	}
 
+	if len(z.Interventions) > 0 {
+		err = z.addPlanningSheet(xls)
+		if err != nil {
+			return nil, fmt.Errorf("Planning : %s", err.Error())
+		}
+	}
+
+	if controls := z.Validate(); len(controls) > 0 {
+		err = z.addControlesSheet(xls, controls)
+		if err != nil {
+			return nil, fmt.Errorf("Contrôles : %s", err.Error())
+		}
+	}
+	return xls, nil
+}
+
+func writeXLSFile(xls *xlsx.File, file string) error {
 	of, err := os.Create(file)
 	if err != nil {
 		return err
@@ -159,33 +365,43 @@ func (z *Zone) addMesuresSheet(xls *xlsx.File) error {
 	}
 
 	node.NewNode().WriteMesuresHeader(sheet)
-	z.Sro.WriteMesuresXLS(sheet, z.Nodes)
+	z.Sro.WriteMesuresXLS(sheet, z.Nodes, z.LossBudget)
 	return nil
 }
 
-func (z *Zone) ParseROPXLS(file string) error {
-	xls, err := xlsx.OpenFile(file)
+// ParseROPXLS parses given ROP file to create zone Node tree. All inconsistencies found in ROP file are returned as Diagnostics.
+//
+// if zone StrictRop is set, parsing stops on first fatal diagnostic, which is also returned as error
+func (z *Zone) ParseROPXLS(file string) (Diagnostics, error) {
+	xls, err := xlsreader.OpenFile(file)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var sheet *xlsx.Sheet
 	for _, sh := range xls.Sheets {
-		if strings.HasPrefix(sh.Name, "TAB") {
+		if z.RopLayout.IsRopSheet(sh.Name) {
 			sheet = sh
 			break
 		}
 	}
 	if sheet == nil {
-		return fmt.Errorf("could not find Tab sheet")
+		return nil, fmt.Errorf("could not find ROP sheet (layout '%s': name starting with '%s')", z.RopLayout.Name, z.RopLayout.SheetPrefix)
 	}
 
 	//parse sheet
 	rp := NewRopParser(sheet, z)
+	rp.Strict = z.StrictRop
 
-	rp.ParseRop()
-
-	return nil
+	diags := rp.ParseRop()
+	for i := range diags {
+		diags[i].Source = filepath.Base(file)
+	}
+	z.Diagnostics = append(z.Diagnostics, diags...)
+	if rp.mustStop(diags) {
+		return diags, diags.Filter(SeverityFatal)
+	}
+	return diags, nil
 }
 
 func (z *Zone) CreateBPETree() {
@@ -263,7 +479,7 @@ const (
 
 func (z *Zone) ParseQuantiteCableXLS(file string) error {
 	baseFile := filepath.Base(file)
-	xls, err := xlsx.OpenFile(file)
+	xls, err := xlsreader.OpenFile(file)
 	if err != nil {
 		return err
 	}
@@ -278,13 +494,13 @@ func (z *Zone) ParseQuantiteCableXLS(file string) error {
 		}
 		tr := z.Troncons[trName]
 		if tr == nil {
-			fmt.Printf("skip unknown Troncon '%s' found on line %d", trName, row+1)
+			z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQCTroncon, row), SeverityWarning, "", trName, "skip unknown troncon")
 			continue
 		}
 		tr.CableType = sheet.Cell(row, colQCCableType).Value
 		tr.LoveLength, err = sheet.Cell(row, colQCLoveLength).Int()
 		if err != nil {
-			fmt.Printf("\t%s: could not read Love length '%s' on line %d, col %d (use default 20m instead)\n", baseFile, sheet.Cell(row, colQCLoveLength).Value, row+1, colQCLoveLength+1)
+			z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQCLoveLength, row), SeverityWarning, "", trName, fmt.Sprintf("could not read Love length '%s' (use default 20m instead)", sheet.Cell(row, colQCLoveLength).Value))
 			tr.LoveLength = 20
 		}
 		// Capa to be check with already existing value
@@ -303,7 +519,7 @@ func (z *Zone) ParseQuantiteCableXLS(file string) error {
 				continue
 			}
 			if err == nil && tirageType != "" {
-				fmt.Printf("\t%s: Unknown tirage type '%s' on line %d, col %d\n", baseFile, tirageType, row+1, colQCTirageType+1)
+				z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQCTirageType, row), SeverityError, "", trName, fmt.Sprintf("unknown tirage type '%s'", tirageType))
 				continue
 			}
 			if err != nil {
@@ -325,7 +541,7 @@ const (
 
 func (z *Zone) ParseQuantiteCableOptiqueC2Xlsx(file string) error {
 	baseFile := filepath.Base(file)
-	xls, err := xlsx.OpenFile(file)
+	xls, err := xlsreader.OpenFile(file)
 	if err != nil {
 		return err
 	}
@@ -335,6 +551,7 @@ func (z *Zone) ParseQuantiteCableOptiqueC2Xlsx(file string) error {
 	}
 
 	type c2Data struct {
+		row         int
 		orig        string
 		dest        string
 		pullingType string
@@ -347,15 +564,16 @@ func (z *Zone) ParseQuantiteCableOptiqueC2Xlsx(file string) error {
 	for row := rowQC2Start; row < sheet.MaxRow; row++ {
 		length, err := sheet.Cell(row, colQC2Length).Float()
 		if err != nil {
-			fmt.Printf("\t%s: could not read length '%s' on line %d, col %d (use default 20m instead)\n", baseFile, sheet.Cell(row, colQC2Length).Value, row+1, colQC2Length+1)
+			z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQC2Length, row), SeverityWarning, sheet.Cell(row, colQC2Dest).Value, "", fmt.Sprintf("could not read length '%s' (use default 20m instead)", sheet.Cell(row, colQC2Length).Value))
 			length = 20
 		}
 		capa, err := sheet.Cell(row, colQC2Capa).Int()
 		if err != nil {
-			fmt.Printf("\t%s: could not read capa '%s' on line %d, col %d (use default 0 instead)\n", baseFile, sheet.Cell(row, colQC2Capa).Value, row+1, colQC2Length+1)
+			z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQC2Capa, row), SeverityWarning, sheet.Cell(row, colQC2Dest).Value, "", fmt.Sprintf("could not read capa '%s' (use default 0 instead)", sheet.Cell(row, colQC2Capa).Value))
 			capa = 0
 		}
 		c := c2Data{
+			row:         row,
 			orig:        sheet.Cell(row, colQC2Orig).Value,
 			dest:        sheet.Cell(row, colQC2Dest).Value,
 			pullingType: sheet.Cell(row, colQC2PullingType).Value,
@@ -365,22 +583,24 @@ func (z *Zone) ParseQuantiteCableOptiqueC2Xlsx(file string) error {
 		c2DataDict[c.dest] = c
 	}
 
-	for _, tr := range z.Troncons {
+	for _, tr := range z.sortedTroncons() {
 		if tr.NodeDest == nil {
-			fmt.Printf("\t%s has no destination node. Skipping\n", tr.Name)
+			z.report(baseFile, "", SeverityError, "", tr.Name, "troncon has no destination node. Skipping")
 			continue
 		}
 		c2, found := c2DataDict[tr.NodeDest.PtName]
 		if !found {
-			fmt.Printf("\t%s has unknown destination node %s. Skipping\n", tr.Name, tr.NodeDest.PtName)
+			z.report(baseFile, "", SeverityError, tr.NodeDest.PtName, tr.Name, "destination node is not declared. Skipping")
 			continue
 		}
 		tr.LoveLength = 0
 		if tr.Capa != c2.capa {
+			capaCell := xlsx.GetCellIDStringFromCoords(colQC2Capa, c2.row)
 			if z.CreateNodeFromRop {
+				z.report(baseFile, capaCell, SeverityWarning, c2.dest, tr.Name, fmt.Sprintf("capacity %d replaced by %d", tr.Capa, c2.capa))
 				tr.Capa = c2.capa
 			} else {
-				fmt.Printf("\t%s has unexpected capacity %d vs %d\n", tr.Name, c2.capa, tr.Capa)
+				z.report(baseFile, capaCell, SeverityError, c2.dest, tr.Name, fmt.Sprintf("unexpected capacity %d vs %d", c2.capa, tr.Capa))
 			}
 		}
 		tr.CableType = fmt.Sprintf("CABLE_%dFO", tr.Capa)
@@ -394,7 +614,7 @@ func (z *Zone) ParseQuantiteCableOptiqueC2Xlsx(file string) error {
 		case strings.Contains(tirageType, "CONDUITE"):
 			tr.UndergroundLength += pullingLength
 		default:
-			fmt.Printf("\t%s has unknown pulling type '%s'\n", tr.Name, tirageType)
+			z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQC2PullingType, c2.row), SeverityError, c2.dest, tr.Name, fmt.Sprintf("unknown pulling type '%s'", tirageType))
 		}
 	}
 	return nil
@@ -410,7 +630,7 @@ const (
 
 func (z *Zone) ParseQuantiteBoiteOptiqueD2Xlsx(file string) error {
 	baseFile := filepath.Base(file)
-	xlsFile, err := xlsx.OpenFile(file)
+	xlsFile, err := xlsreader.OpenFile(file)
 	if err != nil {
 		return err
 	}
@@ -419,36 +639,58 @@ func (z *Zone) ParseQuantiteBoiteOptiqueD2Xlsx(file string) error {
 		return fmt.Errorf("could not find 'NOM' label on cell '%s'", xlsx.GetCellIDStringFromCoords(colQBOName, rowQBOStart-1))
 	}
 
+	// optional coordinates columns (LATITUDE / LONGITUDE headers)
+	colLat, colLong := -1, -1
+	for col := 0; col < sheet.MaxCol; col++ {
+		switch coordinateColumn(sheet.Cell(rowQBOStart-1, col).Value) {
+		case "lat":
+			colLat = col
+		case "long":
+			colLong = col
+		}
+	}
+
 	type boData struct {
-		name     string
-		ref      string
-		boxType  string
-		function string
+		row       int
+		name      string
+		ref       string
+		boxType   string
+		function  string
+		lat, long string
 	}
 
 	boDataDict := make(map[string]boData)
 
 	for row := rowQBOStart; row < sheet.MaxRow; row++ {
 		c := boData{
+			row:      row,
 			name:     sheet.Cell(row, colQBOName).Value,
 			ref:      sheet.Cell(row, colQBOReference).Value,
 			boxType:  sheet.Cell(row, colQBOType).Value,
 			function: sheet.Cell(row, colQBOFunction).Value,
 		}
+		if colLat >= 0 && colLong >= 0 {
+			c.lat, c.long = sheet.Cell(row, colLat).Value, sheet.Cell(row, colLong).Value
+		}
 		boDataDict[c.name] = c
 	}
 
-	for _, node := range z.Nodes {
+	for _, node := range z.sortedNodes() {
 		bo, found := boDataDict[node.PtName]
 		if !found {
-			fmt.Printf("\t%s node is not declared in XLSx file '%s'. Skipping\n", node.PtName, baseFile)
+			z.report(baseFile, "", SeverityWarning, node.PtName, "", "node is not declared. Skipping")
 			continue
 		}
+		if !node.IsLocated() {
+			z.setCoordinates(node, baseFile, xlsx.GetCellIDStringFromCoords(colLat, bo.row), bo.lat, bo.long)
+		}
 		if node.BPEType != bo.ref {
+			refCell := xlsx.GetCellIDStringFromCoords(colQBOReference, bo.row)
 			if z.CreateNodeFromRop {
+				z.report(baseFile, refCell, SeverityWarning, node.PtName, "", fmt.Sprintf("box model '%s' replaced by '%s'", node.BPEType, bo.ref))
 				node.BPEType = bo.ref
 			} else {
-				fmt.Printf("\t%s has unexpected box model '%s' instead of '%s'\n", node.PtName, node.BPEType, bo.ref)
+				z.report(baseFile, refCell, SeverityError, node.PtName, "", fmt.Sprintf("unexpected box model '%s' instead of '%s'", node.BPEType, bo.ref))
 			}
 		}
 		if bo.function != "PBO" {
@@ -458,7 +700,7 @@ func (z *Zone) ParseQuantiteBoiteOptiqueD2Xlsx(file string) error {
 			if z.CreateNodeFromRop {
 				node.LocationType = bo.function
 			} else {
-				fmt.Printf("\t%s has unexpected usage '%s' instead of '%s'\n", node.PtName, node.LocationType, bo.function)
+				z.report(baseFile, xlsx.GetCellIDStringFromCoords(colQBOFunction, bo.row), SeverityError, node.PtName, "", fmt.Sprintf("unexpected usage '%s' instead of '%s'", node.LocationType, bo.function))
 			}
 		}
 	}
@@ -486,6 +728,7 @@ func (z *Zone) WriteJSON(dir, name, client, manager string, siteId int) error {
 		Measurements: nil,
 	}
 
+	z.UpdateReadiness()
 	z.addSiteNodes(site)
 	z.addSiteTroncon(site)
 
@@ -498,18 +741,70 @@ func (z *Zone) WriteJSON(dir, name, client, manager string, siteId int) error {
 		return fmt.Errorf("could not create file:%s\n", err.Error())
 	}
 	defer f.Close()
-	return json.NewEncoder(f).Encode(site)
+	// Junctions and Measurements are completed with their readiness, and Measurements with their loss budget (extra fields are ignored when decoded as ripsites.Site)
+	return json.NewEncoder(f).Encode(struct {
+		*ripsites.Site
+		Junctions    []siteJunction
+		Measurements []siteMeasurement
+	}{site, z.siteJunctions(site), z.siteMeasurements(site)})
+}
+
+// siteJunction is a ripsites junction completed with its readiness and fiber stock
+type siteJunction struct {
+	*ripsites.Junction
+	Readiness node.Readiness
+	Stock     node.FiberStock
+}
+
+func (z *Zone) siteJunctions(site *ripsites.Site) []siteJunction {
+	nodes := map[string]*node.Node{}
+	for _, n := range z.treeNodes() {
+		nodes[n.PtName] = n
+	}
+	res := make([]siteJunction, len(site.Junctions))
+	for i, j := range site.Junctions {
+		res[i].Junction = j
+		if n, found := nodes[j.NodeName]; found {
+			res[i].Readiness = n.JunctionReadiness
+			res[i].Stock = n.Stock
+		}
+	}
+	return res
+}
+
+// siteMeasurement is a ripsites measurement completed with its maximum acceptable loss and readiness
+type siteMeasurement struct {
+	*ripsites.Measurement
+	MaxLoss1310 float64
+	MaxLoss1550 float64
+	Readiness   node.Readiness
+}
+
+func (z *Zone) siteMeasurements(site *ripsites.Site) []siteMeasurement {
+	res := make([]siteMeasurement, len(site.Measurements))
+	for i, m := range site.Measurements {
+		res[i].Measurement = m
+		res[i].MaxLoss1310, res[i].MaxLoss1550 = z.LossBudget.MaxLoss(m.Dist, len(m.NodeNames))
+		if n := z.Nodes[m.DestNodeName]; n != nil {
+			res[i].Readiness = n.MeasurementReadiness
+		}
+	}
+	return res
 }
 
 func (z *Zone) addSiteNodes(site *ripsites.Site) {
 	for _, node := range z.Nodes {
+		tronconInName := ""
+		if node.TronconIn != nil {
+			tronconInName = node.TronconIn.Name
+		}
 		siteNode := &ripsites.Node{
 			Name:          node.PtName,
 			Address:       node.Address,
 			Type:          node.LocationType,
 			BoxType:       node.BPEType,
 			Ref:           node.Name,
-			TronconInName: node.TronconIn.Name,
+			TronconInName: tronconInName,
 			DistFromPm:    node.DistFromPM,
 		}
 		site.Nodes[siteNode.Name] = siteNode
@@ -532,12 +827,11 @@ func (z *Zone) addSitePullings(site *ripsites.Site) {
 		return
 	}
 
-	state := ripsites.MakeState(ripconst.StateToDo)
-
 	for _, cable := range z.Cables {
 		if cable.Troncons[0].CableType == "" {
 			continue
 		}
+		state := siteState(cable.Status, ripconst.StateToDo)
 		sitePulling := &ripsites.Pulling{
 			CableName: cable.Troncons[0].CableType,
 			Chuncks:   nil,
@@ -554,6 +848,9 @@ func (z *Zone) addSitePullings(site *ripsites.Site) {
 				BuildingDist:     tr.FacadeLength,
 				State:            state,
 			}
+			if tr.PullingStatus != nil {
+				chunck.State = siteState(tr.PullingStatus, ripconst.StateToDo)
+			}
 			if chunck.LoveDist+chunck.UndergroundDist+chunck.AerialDist+chunck.BuildingDist == 0 {
 				chunck.LoveDist = 20
 				chunck.UndergroundDist = tr.NodeDest.DistFromPM - tr.NodeSource.DistFromPM
@@ -579,12 +876,9 @@ func (z *Zone) addSiteJunctions(site *ripsites.Site) {
 }
 
 func (z *Zone) addJunction(n *node.Node, site *ripsites.Site) {
-	state := ripsites.MakeState(ripconst.StateToDo)
-	if !z.DoEline && n.BPEType == "ELINE" {
-		state.Status = ripconst.StateCanceled
-		state.Comment = "A ne pas faire"
-	}
-	if !z.DoOtherThanEline && n.BPEType != "ELINE" {
+	state := siteState(n.JunctionStatus, ripconst.StateToDo)
+	// imported field status prevails on activity configuration
+	if z.junctionCanceled(n) {
 		state.Status = ripconst.StateCanceled
 		state.Comment = "A ne pas faire"
 	}
@@ -595,22 +889,9 @@ func (z *Zone) addJunction(n *node.Node, site *ripsites.Site) {
 	}
 
 	for _, opname := range n.Operations() {
-		opeType, trName := "", ""
-		lOpName := strings.ToLower(opname)
-		switch {
-		case strings.HasPrefix(lOpName, "att"):
-			opeType = "Attente"
+		opeType, trName := node.OperationKeyType(opname), node.OperationKeyTroncon(opname)
+		if opeType == opvocab.OpWaiting {
 			trName = ""
-		case strings.HasPrefix(lOpName, "epi"):
-			opeType = "Epissure"
-			if strings.Contains(opname, "->") {
-				trName = strings.Split(opname, "->")[1]
-			}
-		case strings.HasPrefix(lOpName, "pas"):
-			opeType = "Passage"
-			if strings.Contains(opname, "->") {
-				trName = strings.Split(opname, "->")[1]
-			}
 		}
 		e, o := n.GetOperationNumbers(opname)
 		operation := ripsites.Operation{
@@ -641,7 +922,7 @@ func (z *Zone) addSiteMeasurements(site *ripsites.Site) {
 func (z *Zone) addMeasurement(n *node.Node, site *ripsites.Site) {
 	wf := n.GetToBeMeasuredFiber()
 	if wf > 0 {
-		state := ripsites.MakeState(ripconst.StateToDo)
+		state := siteState(n.MeasurementStatus, ripconst.StateToDo)
 
 		measurement := &ripsites.Measurement{
 			DestNodeName: n.PtName,
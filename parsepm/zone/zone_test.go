@@ -0,0 +1,148 @@
+package zone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lpuig/ewin/chantiersalsace/parsepm/node"
+	"github.com/tealeg/xlsx"
+)
+
+// writeTestBPEFile writes in dir a standard layout splice plan for ptName, splicing one fiber from troncon 'in' to troncon 'out'
+func writeTestBPEFile(t *testing.T, dir, ptName, in, out string) {
+	l := node.BPELayouts[0]
+	xf := xlsx.NewFile()
+	sheet, err := xf.AddSheet(l.SheetPrefix + ptName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet.Cell(l.RowPtName, l.ColPtName).SetString(ptName)
+	sheet.Cell(l.RowBPEType, l.ColBPEType).SetString("TENIO T1")
+	row := l.RowFirstFiber
+	sheet.Cell(row, l.ColCableNameIn).SetString(in)
+	sheet.Cell(row, l.ColFiberNumIn).SetString("1")
+	sheet.Cell(row, l.ColOperation).SetString("Epissure")
+	sheet.Cell(row, l.ColFiberNumOut).SetString("1")
+	sheet.Cell(row, l.ColCableNameOut).SetString(out)
+	row++
+	sheet.Cell(row, l.ColTubulure).SetString(l.CableDictMarker + " tubulures")
+	row += l.CableDictSkip + 1
+	sheet.Cell(row, l.ColCableDict).SetString("144 FO-" + in)
+	sheet.Cell(row+1, l.ColCableDict).SetString("12 FO-" + out)
+	err = xf.Save(filepath.Join(dir, ptName+".xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZone_ParseBPEDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestBPEFile(t, dir, "PT 1", "CABLE 1", "CABLE 2")
+	writeTestBPEFile(t, dir, "PT 2", "CABLE 2", "CABLE 3")
+	writeTestBPEFile(t, dir, "PT 3", "CABLE 3", "CABLE 4")
+	for _, bad := range []string{"PT 0 bad.xlsx", "PT 4 bad.xlsx"} {
+		err := ioutil.WriteFile(filepath.Join(dir, bad), []byte("not a splice plan"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	z := New()
+	z.BPEWorkers = 2
+	err := z.ParseBPEDir(dir)
+	if err == nil {
+		t.Fatalf("ParseBPEDir did not return expected error")
+	}
+	if !strings.HasPrefix(err.Error(), "2 BPE file(s) in error") || !strings.Contains(err.Error(), "PT 0 bad.xlsx") || !strings.Contains(err.Error(), "PT 4 bad.xlsx") {
+		t.Errorf("ParseBPEDir returned unexpected error: %s", err.Error())
+	}
+	if len(z.Nodes) != 3 || len(z.Troncons) != 4 {
+		t.Fatalf("unexpected %d nodes and %d troncons", len(z.Nodes), len(z.Troncons))
+	}
+	pt1, pt2 := z.Nodes["PT 1"], z.Nodes["PT 2"]
+	tr := z.Troncons["CABLE 2"]
+	if pt1.TronconsOut["CABLE 2"] != tr || pt2.TronconIn != tr {
+		t.Errorf("nodes do not share zone troncon 'CABLE 2'")
+	}
+	if tr.NodeSource != pt1 || tr.NodeDest != pt2 || tr.Capa != 144 {
+		t.Errorf("unexpected troncon 'CABLE 2' info: capa %d", tr.Capa)
+	}
+}
+
+func TestZone_ParseBPEDir_Patterns(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"lot 2", "old"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestBPEFile(t, dir, "PT 1", "CABLE 1", "CABLE 2")
+	writeTestBPEFile(t, filepath.Join(dir, "lot 2"), "PT 2", "CABLE 2", "CABLE 3")
+	writeTestBPEFile(t, filepath.Join(dir, "old"), "PT 3", "CABLE 3", "CABLE 4")
+	writeTestBPEFile(t, dir, "ROP", "CABLE 1", "CABLE 2")
+	writeTestBPEFile(t, dir, "PT 5_suivi", "CABLE 1", "CABLE 2")
+	other := xlsx.NewFile()
+	if _, err := other.AddSheet("Synthèse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Save(filepath.Join(dir, "PT 4 synthese.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	noHeader := xlsx.NewFile()
+	if _, err := noHeader.AddSheet(node.BPELayouts[0].SheetPrefix + "PT 6"); err != nil {
+		t.Fatal(err)
+	}
+	if err := noHeader.Save(filepath.Join(dir, "PT 6.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "~$PT 1.xlsx"), []byte("temp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "PT 1.xls"), []byte("legacy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	z := New()
+	if err := z.ParseBPEDir(dir); err != nil {
+		t.Fatalf("ParseBPEDir returned unexpected: %s", err.Error())
+	}
+	if len(z.Nodes) != 1 || z.Nodes["PT 1"] == nil {
+		t.Errorf("sub dirs should not be searched by default: unexpected parsed nodes %v", z.Nodes)
+	}
+
+	z = New()
+	z.BPERecursive = true
+	z.BPEExcludes = []string{"old/*"}
+	if err := z.ParseBPEDir(dir); err != nil {
+		t.Fatalf("ParseBPEDir returned unexpected: %s", err.Error())
+	}
+	if len(z.Nodes) != 2 || z.Nodes["PT 1"] == nil || z.Nodes["PT 2"] == nil {
+		t.Errorf("unexpected parsed nodes %v", z.Nodes)
+	}
+	skipped := map[string]bool{}
+	for _, d := range z.Diagnostics {
+		skipped[d.Source] = true
+	}
+	for _, file := range []string{"old/PT 3.xlsx", "PT 1.xls", "PT 4 synthese.xlsx", "PT 6.xlsx", "~$PT 1.xlsx"} {
+		if !skipped[file] {
+			t.Errorf("file '%s' is not reported as skipped", file)
+		}
+	}
+	if len(z.Diagnostics) != 5 {
+		t.Errorf("unexpected %d diagnostics", len(z.Diagnostics))
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	for file, expected := range map[string]bool{"PT 1.xlsx": true, "PT 1.xls": true, "lot 2/PT 2.xls": true, "ROP.xlsx": false} {
+		if _, found := matchPatterns([]string{Blobpattern_EasyFibre}, file); found != expected {
+			t.Errorf("EasyFibre pattern matching '%s' returned %v", file, found)
+		}
+	}
+	if _, found := matchPatterns([]string{Blobpattern_Sogetrel}, "lot 2/_PT 2.xls"); !found {
+		t.Errorf("Sogetrel pattern should match legacy workbooks")
+	}
+}
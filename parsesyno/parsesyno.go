@@ -3,6 +3,7 @@ package parsesyno
 import (
 	"fmt"
 	"github.com/lpuig/ewin/chantiersalsace/parsesyno/site"
+	"github.com/lpuig/ewin/chantiersalsace/xlsreader"
 	"github.com/tealeg/xlsx"
 	"os"
 	"path/filepath"
@@ -34,7 +35,7 @@ type Syno struct {
 }
 
 func (s *Syno) Parse() error {
-	xf, err := xlsx.OpenFile(s.File)
+	xf, err := xlsreader.OpenFile(s.File)
 	if err != nil {
 		return err
 	}
@@ -2,6 +2,7 @@ package parsezacable
 
 import (
 	"fmt"
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
 	"github.com/tealeg/xlsx"
 	"sort"
 	"strings"
@@ -32,7 +33,7 @@ func NewLink() Link {
 func (l Link) GetNumbers() (nbEpi, nbOthers int) {
 	for op, n := range l {
 		switch op {
-		case "EPI":
+		case opvocab.OpSplice:
 			nbEpi += n
 		default:
 			nbOthers += n
@@ -52,6 +53,8 @@ type Site struct {
 	CableIn string // CDI-68-048-DXA-1010 (>CABLE , 1)
 
 	Links map[Dest]Link //Map[DestSite (row, 9)]Link
+
+	UnknownOperations map[string]int // number of fibers per operation label not found in vocabulary (not counted in Links)
 }
 
 func NewSite(fname string) *Site {
@@ -59,6 +62,8 @@ func NewSite(fname string) *Site {
 		FullName: fname,
 		Name:     GetShortSiteName(fname), //keep 1010 in PBO-68-048-DXA-1010
 		Links:    make(map[Dest]Link),
+
+		UnknownOperations: make(map[string]int),
 	}
 	return s
 }
@@ -105,10 +110,10 @@ func (s *Site) AddLink(ope string, cableout Dest) {
 	}
 	//if cableout.cable == "" {
 	switch ope {
-	case "LOV":
+	case opvocab.OpLove:
 		cableout.cable = lovage
 		cableout.capa = ""
-	case "PAS":
+	case opvocab.OpPassage:
 		cableout.cable = passage
 		cableout.capa = ""
 	default:
@@ -125,7 +130,8 @@ func (s *Site) AddLink(ope string, cableout Dest) {
 	l[ope]++
 }
 
-func (s *Site) ParseXLSSheet(xsh *xlsx.Sheet) error {
+// ParseXLSSheet populates the receiver site with given ZACABLE sheet. Operation labels are mapped to canonical types with given vocabulary
+func (s *Site) ParseXLSSheet(xsh *xlsx.Sheet, vocab opvocab.Vocabulary) error {
 	//name := xsh.Cell(0, 0).Value
 	//if name != s.FullName {
 	//	return fmt.Errorf("site fullname does not match XLS info ('%s' vs '%s)", s.FullName, name)
@@ -144,7 +150,11 @@ func (s *Site) ParseXLSSheet(xsh *xlsx.Sheet) error {
 		if cable == "" {
 			break
 		}
-		nope := xsh.Cell(row, 4).Value
+		label := xsh.Cell(row, 4).Value
+		nope, known := vocab.Canonical(opvocab.SourceZACable, label)
+		if !known {
+			s.UnknownOperations[label]++
+		}
 		if nope == "" {
 			cableout = Dest{}
 			ope = nope
@@ -160,8 +170,8 @@ func (s *Site) ParseXLSSheet(xsh *xlsx.Sheet) error {
 			cableout.cable = nco
 			cableout.capa = ncocapa
 		}
-		if cableout.cable == "" && ope != "LOV" && xsh.Cell(row, 9).Value == s.FullName {
-			ope = "LOV"
+		if cableout.cable == "" && ope != opvocab.OpLove && xsh.Cell(row, 9).Value == s.FullName {
+			ope = opvocab.OpLove
 		}
 		s.AddLink(ope, cableout)
 	}
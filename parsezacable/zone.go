@@ -2,6 +2,7 @@ package parsezacable
 
 import (
 	"fmt"
+	"github.com/lpuig/ewin/chantiersalsace/opvocab"
 	"github.com/tealeg/xlsx"
 	"os"
 	"path/filepath"
@@ -15,6 +16,8 @@ type Zone struct {
 	Sites     []*Site
 	Index     map[string]int
 	SynoIndex map[string]int
+
+	Vocabulary opvocab.Vocabulary // operation labels synonyms
 }
 
 func NewZone(name string) *Zone {
@@ -23,6 +26,8 @@ func NewZone(name string) *Zone {
 		Sites:     []*Site{},
 		Index:     make(map[string]int),
 		SynoIndex: make(map[string]int),
+
+		Vocabulary: opvocab.DefaultVocabulary(),
 	}
 	return z
 }
@@ -75,10 +80,18 @@ func (z *Zone) GetSiteFullNames() []string {
 
 func (z *Zone) ParseXLSSheet(xsh *xlsx.Sheet) error {
 	s := NewSite(xsh.Name)
-	err := s.ParseXLSSheet(xsh)
+	err := s.ParseXLSSheet(xsh, z.Vocabulary)
 	if err != nil {
 		return err
 	}
+	labels := []string{}
+	for label := range s.UnknownOperations {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		fmt.Printf("\tWarning site %s : unknown operation '%s' (%d fibers) : not counted\n", s.FullName, label, s.UnknownOperations[label])
+	}
 	z.Add(s)
 	return nil
 }
@@ -3,7 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/lpuig/ewin/chantiersalsace/dirbrowser"
-	"github.com/lpuig/ewin/chantiersalsace/xlsxconvert"
+	"github.com/lpuig/ewin/chantiersalsace/xlsreader"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 	"log"
@@ -137,9 +137,9 @@ func (gc *GuiContext) ConvertDirToXlsx(dir string) {
 
 func (gc *GuiContext) ConvertToXlsx(filename string) {
 	gc.Logf("Converting file %s :\r\n", filepath.Base(filename))
-	errs := xlsxconvert.OleXlsToXlsx(filename)
-	if len(errs) > 1 && errs[0] != nil {
-		gc.Logf("\tfailed : %s\r\n", errs[0].Error())
+	_, err := xlsreader.ConvertToXlsx(filename)
+	if err != nil {
+		gc.Logf("\tfailed : %s\r\n", err.Error())
 		return
 	}
 	if !gc.eraseFileCB.Checked() {
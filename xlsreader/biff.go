@@ -0,0 +1,208 @@
+package xlsreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// BIFF8 record types
+const (
+	recBOF        uint16 = 0x0809
+	recEOF        uint16 = 0x000A
+	recContinue   uint16 = 0x003C
+	recFilePass   uint16 = 0x002F
+	recBoundSheet uint16 = 0x0085
+	recSST        uint16 = 0x00FC
+	recXF         uint16 = 0x00E0
+	recPalette    uint16 = 0x0092
+	recLabelSST   uint16 = 0x00FD
+	recLabel      uint16 = 0x0204
+	recNumber     uint16 = 0x0203
+	recRK         uint16 = 0x027E
+	recMulRK      uint16 = 0x00BD
+	recBlank      uint16 = 0x0201
+	recMulBlank   uint16 = 0x00BE
+	recBoolErr    uint16 = 0x0205
+	recFormula    uint16 = 0x0006
+	recString     uint16 = 0x0207
+	recMergeCells uint16 = 0x00E5
+	recColInfo    uint16 = 0x007D
+
+	biff8Version uint16 = 0x0600
+)
+
+// record is a BIFF record, along with its CONTINUE records data
+type record struct {
+	typ  uint16
+	pos  int // record offset in workbook stream
+	data []byte
+	cont [][]byte
+}
+
+// readRecords splits given workbook stream into records. Trailing incomplete record (if any) is ignored
+func readRecords(stream []byte) []*record {
+	recs := []*record{}
+	for pos := 0; pos+4 <= len(stream); {
+		typ := binary.LittleEndian.Uint16(stream[pos:])
+		size := int(binary.LittleEndian.Uint16(stream[pos+2:]))
+		if pos+4+size > len(stream) {
+			break
+		}
+		data := stream[pos+4 : pos+4+size]
+		if typ == recContinue && len(recs) > 0 {
+			last := recs[len(recs)-1]
+			last.cont = append(last.cont, data)
+		} else {
+			recs = append(recs, &record{typ: typ, pos: pos, data: data})
+		}
+		pos += 4 + size
+	}
+	return recs
+}
+
+// recordReader reads a record data, going on with its CONTINUE records data when needed
+type recordReader struct {
+	segs [][]byte
+	seg  int
+	pos  int
+	err  error
+}
+
+func newRecordReader(r *record) *recordReader {
+	return &recordReader{segs: append([][]byte{r.data}, r.cont...)}
+}
+
+func (rr *recordReader) bytes(n int) []byte {
+	res := make([]byte, 0, n)
+	for len(res) < n {
+		if rr.seg >= len(rr.segs) {
+			rr.err = fmt.Errorf("unexpected end of record")
+			return make([]byte, n)
+		}
+		seg := rr.segs[rr.seg]
+		if rr.pos >= len(seg) {
+			rr.seg++
+			rr.pos = 0
+			continue
+		}
+		k := n - len(res)
+		if k > len(seg)-rr.pos {
+			k = len(seg) - rr.pos
+		}
+		res = append(res, seg[rr.pos:rr.pos+k]...)
+		rr.pos += k
+	}
+	return res
+}
+
+func (rr *recordReader) skip(n int) {
+	rr.bytes(n)
+}
+
+func (rr *recordReader) u8() byte {
+	return rr.bytes(1)[0]
+}
+
+func (rr *recordReader) u16() uint16 {
+	return binary.LittleEndian.Uint16(rr.bytes(2))
+}
+
+func (rr *recordReader) u32() uint32 {
+	return binary.LittleEndian.Uint32(rr.bytes(4))
+}
+
+func (rr *recordReader) f64() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(rr.bytes(8)))
+}
+
+// chars reads nb characters, 16 bits ones if highByte is set or compressed 8 bits ones otherwise.
+//
+// When characters are split by a CONTINUE record, the latter starts with an option flags byte giving the encoding of the following characters
+func (rr *recordReader) chars(nb int, highByte bool) string {
+	res := make([]uint16, 0, nb)
+	for len(res) < nb {
+		if rr.seg >= len(rr.segs) {
+			rr.err = fmt.Errorf("unexpected end of record")
+			break
+		}
+		if rr.pos >= len(rr.segs[rr.seg]) {
+			rr.seg++
+			rr.pos = 0
+			if rr.seg < len(rr.segs) {
+				highByte = rr.u8()&0x01 != 0
+			}
+			continue
+		}
+		if highByte {
+			res = append(res, rr.u16())
+		} else {
+			res = append(res, uint16(rr.u8()))
+		}
+	}
+	return string(utf16.Decode(res))
+}
+
+// unicodeString reads a XLUnicodeString (ShortXLUnicodeString if short is set : 8 bits length)
+func (rr *recordReader) unicodeString(short bool) string {
+	var nb int
+	if short {
+		nb = int(rr.u8())
+	} else {
+		nb = int(rr.u16())
+	}
+	flags := rr.u8()
+	return rr.chars(nb, flags&0x01 != 0)
+}
+
+// richString reads a XLUnicodeRichExtendedString (shared strings table item), skipping its formatting runs and phonetic info
+func (rr *recordReader) richString() string {
+	nb := int(rr.u16())
+	flags := rr.u8()
+	nbRuns, extSize := 0, 0
+	if flags&0x08 != 0 {
+		nbRuns = int(rr.u16())
+	}
+	if flags&0x04 != 0 {
+		extSize = int(rr.u32())
+	}
+	res := rr.chars(nb, flags&0x01 != 0)
+	rr.skip(4*nbRuns + extSize)
+	return res
+}
+
+// rkValue decodes given RK number
+func rkValue(rk uint32) float64 {
+	var res float64
+	if rk&0x02 != 0 {
+		res = float64(int32(rk) >> 2)
+	} else {
+		res = math.Float64frombits(uint64(rk&0xFFFFFFFC) << 32)
+	}
+	if rk&0x01 != 0 {
+		res /= 100
+	}
+	return res
+}
+
+// errorValue returns the label of given BIFF error code
+func errorValue(code byte) string {
+	switch code {
+	case 0x00:
+		return "#NULL!"
+	case 0x07:
+		return "#DIV/0!"
+	case 0x0F:
+		return "#VALUE!"
+	case 0x17:
+		return "#REF!"
+	case 0x1D:
+		return "#NAME?"
+	case 0x24:
+		return "#NUM!"
+	case 0x2A:
+		return "#N/A"
+	}
+	return "#ERR!"
+}
@@ -0,0 +1,214 @@
+package xlsreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Compound File Binary (OLE2 structured storage) container, holding the BIFF8 workbook stream of XLS files
+
+const (
+	cfbSignature    uint64 = 0xE11AB1A1E011CFD0
+	cfbHeaderSize   int    = 512
+	cfbDirEntrySize int    = 128
+	cfbNbHeaderFAT  int    = 109
+
+	cfbEndOfChain uint32 = 0xFFFFFFFE
+	cfbFreeSect   uint32 = 0xFFFFFFFF
+
+	cfbTypeStream byte = 2
+	cfbTypeRoot   byte = 5
+)
+
+// cfbEntry is a compound file directory entry
+type cfbEntry struct {
+	name  string
+	typ   byte
+	start uint32
+	size  uint64
+}
+
+// compoundFile gives access to streams of a compound file content
+type compoundFile struct {
+	content        []byte
+	sectorSize     int
+	miniSectorSize int
+	miniCutoff     uint64
+	fat            []uint32
+	miniFat        []uint32
+	miniStream     []byte
+	entries        []cfbEntry
+}
+
+func newCompoundFile(content []byte) (*compoundFile, error) {
+	if len(content) < cfbHeaderSize || binary.LittleEndian.Uint64(content) != cfbSignature {
+		return nil, fmt.Errorf("not a compound file (OLE2) document")
+	}
+	sectorShift := binary.LittleEndian.Uint16(content[0x1E:])
+	if sectorShift != 9 && sectorShift != 12 {
+		return nil, fmt.Errorf("invalid compound file sector shift %d", sectorShift)
+	}
+	miniSectorShift := binary.LittleEndian.Uint16(content[0x20:])
+	if miniSectorShift != 6 {
+		return nil, fmt.Errorf("invalid compound file mini sector shift %d", miniSectorShift)
+	}
+	cf := &compoundFile{
+		content:        content,
+		sectorSize:     1 << sectorShift,
+		miniSectorSize: 1 << miniSectorShift,
+		miniCutoff:     uint64(binary.LittleEndian.Uint32(content[0x38:])),
+	}
+	nbFat := int(binary.LittleEndian.Uint32(content[0x2C:]))
+	firstDir := binary.LittleEndian.Uint32(content[0x30:])
+	firstMiniFat := binary.LittleEndian.Uint32(content[0x3C:])
+	firstDifat := binary.LittleEndian.Uint32(content[0x44:])
+
+	// FAT sectors are listed in header DIFAT, then in DIFAT sectors chain
+	fatSectors := []uint32{}
+	for i := 0; i < cfbNbHeaderFAT && len(fatSectors) < nbFat; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(content[0x4C+4*i:]))
+	}
+	nbPerSector := cf.sectorSize / 4
+	for sect, nb := firstDifat, 0; sect != cfbEndOfChain && sect != cfbFreeSect && len(fatSectors) < nbFat; nb++ {
+		data, err := cf.sector(sect)
+		if err != nil {
+			return nil, err
+		}
+		if nb > len(content)/cf.sectorSize {
+			return nil, fmt.Errorf("DIFAT sectors chain loops")
+		}
+		for i := 0; i < nbPerSector-1 && len(fatSectors) < nbFat; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		sect = binary.LittleEndian.Uint32(data[4*(nbPerSector-1):])
+	}
+	for _, sect := range fatSectors {
+		data, err := cf.sector(sect)
+		if err != nil {
+			return nil, err
+		}
+		cf.fat = append(cf.fat, uint32s(data)...)
+	}
+
+	dir, err := cf.chain(firstDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory: %s", err.Error())
+	}
+	for pos := 0; pos+cfbDirEntrySize <= len(dir); pos += cfbDirEntrySize {
+		cf.entries = append(cf.entries, newCfbEntry(dir[pos:pos+cfbDirEntrySize]))
+	}
+	if len(cf.entries) == 0 || cf.entries[0].typ != cfbTypeRoot {
+		return nil, fmt.Errorf("could not find root directory entry")
+	}
+
+	if firstMiniFat != cfbEndOfChain {
+		miniFat, err := cf.chain(firstMiniFat)
+		if err != nil {
+			return nil, fmt.Errorf("could not read mini FAT: %s", err.Error())
+		}
+		cf.miniFat = uint32s(miniFat)
+		root := cf.entries[0]
+		cf.miniStream, err = cf.chain(root.start)
+		if err != nil {
+			return nil, fmt.Errorf("could not read mini stream: %s", err.Error())
+		}
+	}
+	return cf, nil
+}
+
+func newCfbEntry(data []byte) cfbEntry {
+	nameLen := int(binary.LittleEndian.Uint16(data[64:])) / 2
+	if nameLen > 32 {
+		nameLen = 32
+	}
+	name := make([]uint16, 0, nameLen)
+	for i := 0; i < nameLen; i++ {
+		c := binary.LittleEndian.Uint16(data[2*i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+	return cfbEntry{
+		name:  string(utf16.Decode(name)),
+		typ:   data[66],
+		start: binary.LittleEndian.Uint32(data[116:]),
+		size:  binary.LittleEndian.Uint64(data[120:]) & 0xFFFFFFFF, // high part is not reliable for 512 bytes sector files
+	}
+}
+
+func uint32s(data []byte) []uint32 {
+	res := make([]uint32, len(data)/4)
+	for i := range res {
+		res[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return res
+}
+
+// sector returns the content of given (regular) sector
+func (cf *compoundFile) sector(sect uint32) ([]byte, error) {
+	pos := (int(sect) + 1) * cf.sectorSize
+	if pos+cf.sectorSize > len(cf.content) {
+		return nil, fmt.Errorf("invalid sector %d", sect)
+	}
+	return cf.content[pos : pos+cf.sectorSize], nil
+}
+
+// chain returns the content of the regular sectors chain starting at given sector
+func (cf *compoundFile) chain(start uint32) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for sect, nb := start, 0; sect != cfbEndOfChain; nb++ {
+		if int(sect) >= len(cf.fat) || nb > len(cf.fat) {
+			return nil, fmt.Errorf("invalid sectors chain")
+		}
+		data, err := cf.sector(sect)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		sect = cf.fat[sect]
+	}
+	return buf.Bytes(), nil
+}
+
+// miniChain returns the content of the mini sectors chain starting at given mini sector
+func (cf *compoundFile) miniChain(start uint32) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for sect, nb := start, 0; sect != cfbEndOfChain; nb++ {
+		pos := int(sect) * cf.miniSectorSize
+		if int(sect) >= len(cf.miniFat) || nb > len(cf.miniFat) || pos+cf.miniSectorSize > len(cf.miniStream) {
+			return nil, fmt.Errorf("invalid mini sectors chain")
+		}
+		buf.Write(cf.miniStream[pos : pos+cf.miniSectorSize])
+		sect = cf.miniFat[sect]
+	}
+	return buf.Bytes(), nil
+}
+
+// stream returns the content of the stream having one of given names (first found)
+func (cf *compoundFile) stream(names ...string) ([]byte, error) {
+	for _, name := range names {
+		for _, e := range cf.entries {
+			if e.typ != cfbTypeStream || e.name != name {
+				continue
+			}
+			var data []byte
+			var err error
+			if e.size < cf.miniCutoff {
+				data, err = cf.miniChain(e.start)
+			} else {
+				data, err = cf.chain(e.start)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not read stream '%s': %s", name, err.Error())
+			}
+			if uint64(len(data)) < e.size {
+				return nil, fmt.Errorf("stream '%s' is truncated", name)
+			}
+			return data[:e.size], nil
+		}
+	}
+	return nil, fmt.Errorf("could not find stream '%s'", names[0])
+}
@@ -0,0 +1,117 @@
+package xlsreader
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/tealeg/xlsx"
+)
+
+// defaultPalette lists BIFF8 default colors (RRGGBB), for color indexes 8 to 63
+var defaultPalette = []string{
+	"000000", "FFFFFF", "FF0000", "00FF00", "0000FF", "FFFF00", "FF00FF", "00FFFF",
+	"800000", "008000", "000080", "808000", "800080", "008080", "C0C0C0", "808080",
+	"9999FF", "993366", "FFFFCC", "CCFFFF", "660066", "FF8080", "0066CC", "CCCCFF",
+	"000080", "FF00FF", "FFFF00", "00FFFF", "800080", "800000", "008080", "0000FF",
+	"00CCFF", "CCFFFF", "CCFFCC", "FFFF99", "99CCFF", "FF99CC", "CC99FF", "FFCC99",
+	"3366FF", "33CCCC", "99CC00", "FFCC00", "FF9900", "FF6600", "666699", "969696",
+	"003366", "339966", "003300", "333300", "993300", "993366", "333399", "333333",
+}
+
+const (
+	paletteOffset   int = 8  // first customizable color index
+	colorSystemText int = 64 // system window text color index
+	colorSystemBack int = 65 // system window background color index
+)
+
+// borderStyles lists border line styles, by BIFF8 line style code
+var borderStyles = []string{
+	"none", "thin", "medium", "dashed", "dotted", "thick", "double", "hair",
+	"mediumDashed", "dashDot", "mediumDashDot", "dashDotDot", "mediumDashDotDot", "slantDashDot",
+}
+
+// fillPatterns lists fill pattern types, by BIFF8 fill pattern code
+var fillPatterns = []string{
+	"none", "solid", "mediumGray", "darkGray", "lightGray", "darkHorizontal", "darkVertical", "darkDown", "darkUp",
+	"darkGrid", "darkTrellis", "lightHorizontal", "lightVertical", "lightDown", "lightUp", "lightGrid", "lightTrellis",
+	"gray125", "gray0625",
+}
+
+// palette holds workbook colors (RRGGBB), by color index
+type palette []string
+
+func newPalette() palette {
+	p := make(palette, paletteOffset, paletteOffset+len(defaultPalette))
+	copy(p, defaultPalette[:paletteOffset])
+	return append(p, defaultPalette...)
+}
+
+// setColors overrides palette colors with given PALETTE record ones
+func (p palette) setColors(r *record) {
+	rr := newRecordReader(r)
+	nb := int(rr.u16())
+	for i := 0; i < nb && rr.err == nil && paletteOffset+i < len(p); i++ {
+		rgb := rr.bytes(4)
+		if rr.err != nil {
+			break
+		}
+		p[paletteOffset+i] = fmt.Sprintf("%02X%02X%02X", rgb[0], rgb[1], rgb[2])
+	}
+}
+
+// color returns the ARGB color (as used by xlsx styles) of given color index ("" for automatic or unknown color)
+func (p palette) color(index int) string {
+	switch {
+	case index < len(p):
+		return "FF" + p[index]
+	case index == colorSystemText:
+		return "FF000000"
+	case index == colorSystemBack:
+		return "FFFFFFFF"
+	}
+	return ""
+}
+
+func lookup(labels []string, code uint32) string {
+	if int(code) < len(labels) {
+		return labels[code]
+	}
+	return labels[0]
+}
+
+// newStyle returns the xlsx style (borders and fill) described by given XF record data
+func newStyle(xf []byte, p palette) *xlsx.Style {
+	st := xlsx.NewStyle()
+	if len(xf) < 20 {
+		return st
+	}
+	borders := binary.LittleEndian.Uint32(xf[10:])
+	borders2 := binary.LittleEndian.Uint32(xf[14:])
+	colors := uint32(binary.LittleEndian.Uint16(xf[18:]))
+
+	sides := []struct {
+		line  *string
+		color *string
+		style uint32
+		icv   uint32
+	}{
+		{&st.Border.Left, &st.Border.LeftColor, borders & 0x0F, borders >> 16 & 0x7F},
+		{&st.Border.Right, &st.Border.RightColor, borders >> 4 & 0x0F, borders >> 23 & 0x7F},
+		{&st.Border.Top, &st.Border.TopColor, borders >> 8 & 0x0F, borders2 & 0x7F},
+		{&st.Border.Bottom, &st.Border.BottomColor, borders >> 12 & 0x0F, borders2 >> 7 & 0x7F},
+	}
+	for _, side := range sides {
+		if side.style == 0 {
+			continue
+		}
+		*side.line = lookup(borderStyles, side.style)
+		*side.color = p.color(int(side.icv))
+		st.ApplyBorder = true
+	}
+
+	if pattern := borders2 >> 26 & 0x3F; pattern != 0 {
+		st.Fill = *xlsx.NewFill(lookup(fillPatterns, pattern), p.color(int(colors&0x7F)), p.color(int(colors>>7&0x7F)))
+		st.ApplyFill = true
+	}
+	return st
+}
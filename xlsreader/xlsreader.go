@@ -0,0 +1,295 @@
+// Package xlsreader reads legacy Excel 97-2003 workbooks (BIFF8 .xls files) without Excel, as tealeg xlsx Files.
+//
+// Cells values (formulas giving their last computed value), merged cells, column widths and cells border and fill styles are retrieved.
+// Fonts, number formats and formulas themselves are not.
+package xlsreader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+)
+
+// OpenFile opens given workbook file : .xls files are read with OpenXls, other ones with xlsx.OpenFile
+func OpenFile(file string) (*xlsx.File, error) {
+	if IsXls(file) {
+		return OpenXls(file)
+	}
+	return xlsx.OpenFile(file)
+}
+
+// IsXls returns true if given file name has a .xls extension
+func IsXls(file string) bool {
+	return strings.ToLower(filepath.Ext(file)) == ".xls"
+}
+
+// OpenXls reads given BIFF8 .xls workbook file
+func OpenXls(file string) (*xlsx.File, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	xf, err := ReadXls(content)
+	if err != nil {
+		return nil, fmt.Errorf("could not read '%s': %s", filepath.Base(file), err.Error())
+	}
+	return xf, nil
+}
+
+// ConvertToXlsx converts given .xls workbook file to XLSx, written next to it with .xlsx extension, and returns the written file name
+func ConvertToXlsx(file string) (string, error) {
+	xf, err := OpenXls(file)
+	if err != nil {
+		return "", err
+	}
+	outFile := strings.TrimSuffix(file, filepath.Ext(file)) + ".xlsx"
+	return outFile, xf.Save(outFile)
+}
+
+// ReadXls reads given BIFF8 .xls workbook content
+func ReadXls(content []byte) (*xlsx.File, error) {
+	cf, err := newCompoundFile(content)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := cf.stream("Workbook", "WORKBOOK", "workbook")
+	if err != nil {
+		if _, errBook := cf.stream("Book"); errBook == nil {
+			return nil, fmt.Errorf("Excel 5.0/95 workbooks are not supported")
+		}
+		return nil, err
+	}
+	wb := &workbook{palette: newPalette(), styles: map[int]*xlsx.Style{}}
+	return wb.read(readRecords(stream))
+}
+
+// boundSheet is a worksheet declared in workbook globals
+type boundSheet struct {
+	name string
+	pos  int // sheet BOF record offset in workbook stream
+}
+
+// workbook holds the workbook globals needed to read worksheets
+type workbook struct {
+	sst     []string
+	xfs     [][]byte
+	palette palette
+	styles  map[int]*xlsx.Style
+	sheets  []boundSheet
+}
+
+func (wb *workbook) read(recs []*record) (*xlsx.File, error) {
+	if len(recs) == 0 || recs[0].typ != recBOF || len(recs[0].data) < 2 || binary.LittleEndian.Uint16(recs[0].data) != biff8Version {
+		return nil, fmt.Errorf("not a BIFF8 (Excel 97-2003) workbook")
+	}
+	// Workbook globals substream
+	i := 1
+	for ; i < len(recs) && recs[i].typ != recEOF; i++ {
+		r := recs[i]
+		switch r.typ {
+		case recFilePass:
+			return nil, fmt.Errorf("encrypted workbooks are not supported")
+		case recBoundSheet:
+			rr := newRecordReader(r)
+			pos := int(rr.u32())
+			rr.u8() // visibility
+			if kind := rr.u8(); kind != 0 {
+				continue // skip macro, chart and VB module sheets
+			}
+			name := rr.unicodeString(true)
+			if rr.err != nil {
+				return nil, fmt.Errorf("could not read sheet declaration: %s", rr.err.Error())
+			}
+			wb.sheets = append(wb.sheets, boundSheet{name: name, pos: pos})
+		case recSST:
+			rr := newRecordReader(r)
+			rr.u32() // total number of strings
+			nb := int(rr.u32())
+			wb.sst = make([]string, 0, nb)
+			for j := 0; j < nb && rr.err == nil; j++ {
+				wb.sst = append(wb.sst, rr.richString())
+			}
+			if rr.err != nil {
+				return nil, fmt.Errorf("could not read shared strings: %s", rr.err.Error())
+			}
+		case recXF:
+			wb.xfs = append(wb.xfs, r.data)
+		case recPalette:
+			wb.palette.setColors(r)
+		}
+	}
+
+	xf := xlsx.NewFile()
+	for _, bs := range wb.sheets {
+		start := -1
+		for j := i; j < len(recs); j++ {
+			if recs[j].pos == bs.pos {
+				start = j
+				break
+			}
+		}
+		if start < 0 || recs[start].typ != recBOF {
+			return nil, fmt.Errorf("could not find sheet '%s' content", bs.name)
+		}
+		sheet, err := xf.AddSheet(bs.name)
+		if err != nil {
+			return nil, err
+		}
+		err = wb.readSheet(sheet, recs[start:])
+		if err != nil {
+			return nil, fmt.Errorf("sheet '%s': %s", bs.name, err.Error())
+		}
+	}
+	return xf, nil
+}
+
+// style returns the xlsx style related to given XF index (nil if unknown)
+func (wb *workbook) style(ixfe int) *xlsx.Style {
+	if ixfe < 0 || ixfe >= len(wb.xfs) {
+		return nil
+	}
+	st, found := wb.styles[ixfe]
+	if !found {
+		st = newStyle(wb.xfs[ixfe], wb.palette)
+		wb.styles[ixfe] = st
+	}
+	return st
+}
+
+// cell returns sheet cell at given position, with given XF index style
+func (wb *workbook) cell(sheet *xlsx.Sheet, row, col, ixfe int) *xlsx.Cell {
+	c := sheet.Cell(row, col)
+	if st := wb.style(ixfe); st != nil {
+		c.SetStyle(st)
+	}
+	return c
+}
+
+// readSheet populates sheet with given worksheet substream records (starting with its BOF record)
+func (wb *workbook) readSheet(sheet *xlsx.Sheet, recs []*record) error {
+	type colInfo struct {
+		first, last int
+		width       float64
+	}
+	colInfos := []colInfo{}
+	var stringCell *xlsx.Cell // formula cell waiting for its STRING record value
+	depth := 0
+records:
+	for _, r := range recs {
+		switch r.typ {
+		case recBOF:
+			depth++ // embedded chart substreams are skipped
+			continue
+		case recEOF:
+			depth--
+			if depth == 0 {
+				break records
+			}
+			continue
+		}
+		if depth > 1 {
+			continue
+		}
+		rr := newRecordReader(r)
+		switch r.typ {
+		case recLabelSST:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			isst := int(rr.u32())
+			c := wb.cell(sheet, row, col, ixfe)
+			if isst < len(wb.sst) {
+				c.SetString(wb.sst[isst])
+			}
+		case recLabel:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			value := rr.unicodeString(false)
+			wb.cell(sheet, row, col, ixfe).SetString(value)
+		case recNumber:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			value := rr.f64()
+			wb.cell(sheet, row, col, ixfe).SetFloat(value)
+		case recRK:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			value := rkValue(rr.u32())
+			wb.cell(sheet, row, col, ixfe).SetFloat(value)
+		case recMulRK:
+			row, col := int(rr.u16()), int(rr.u16())
+			for nb := (len(r.data) - 6) / 6; nb > 0; nb-- {
+				ixfe := int(rr.u16())
+				wb.cell(sheet, row, col, ixfe).SetFloat(rkValue(rr.u32()))
+				col++
+			}
+		case recBlank:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			wb.cell(sheet, row, col, ixfe)
+		case recMulBlank:
+			row, col := int(rr.u16()), int(rr.u16())
+			for nb := (len(r.data) - 6) / 2; nb > 0; nb-- {
+				wb.cell(sheet, row, col, int(rr.u16()))
+				col++
+			}
+		case recBoolErr:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			value, isError := rr.u8(), rr.u8()
+			c := wb.cell(sheet, row, col, ixfe)
+			if isError != 0 {
+				c.SetString(errorValue(value))
+			} else {
+				c.SetBool(value != 0)
+			}
+		case recFormula:
+			row, col, ixfe := int(rr.u16()), int(rr.u16()), int(rr.u16())
+			result := rr.bytes(8)
+			c := wb.cell(sheet, row, col, ixfe)
+			if binary.LittleEndian.Uint16(result[6:]) != 0xFFFF {
+				c.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(result)))
+				break
+			}
+			switch result[0] {
+			case 0: // string value given by following STRING record
+				stringCell = c
+			case 1:
+				c.SetBool(result[2] != 0)
+			case 2:
+				c.SetString(errorValue(result[2]))
+			}
+		case recString:
+			if stringCell != nil {
+				stringCell.SetString(rr.unicodeString(false))
+				stringCell = nil
+			}
+		case recMergeCells:
+			for nb := int(rr.u16()); nb > 0 && rr.err == nil; nb-- {
+				rowFirst, rowLast, colFirst, colLast := int(rr.u16()), int(rr.u16()), int(rr.u16()), int(rr.u16())
+				if rr.err == nil {
+					sheet.Cell(rowFirst, colFirst).Merge(colLast-colFirst, rowLast-rowFirst)
+				}
+			}
+		case recColInfo:
+			first, last, width := int(rr.u16()), int(rr.u16()), float64(rr.u16())/256
+			colInfos = append(colInfos, colInfo{first, last, width})
+		}
+		if rr.err != nil {
+			return fmt.Errorf("could not read record 0x%04X at offset %d: %s", r.typ, r.pos, rr.err.Error())
+		}
+	}
+
+	// column widths are only set on used columns (COLINFO ranges usually span up to last sheet column)
+	for _, ci := range colInfos {
+		if ci.last >= sheet.MaxCol {
+			ci.last = sheet.MaxCol - 1
+		}
+		if ci.first > ci.last {
+			continue
+		}
+		err := sheet.SetColWidth(ci.first, ci.last, ci.width)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package xlsreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// testRecords builds a BIFF8 workbook stream record by record
+type testRecords struct {
+	bytes.Buffer
+}
+
+func (tr *testRecords) add(typ uint16, fields ...interface{}) {
+	data := bytes.Buffer{}
+	for _, f := range fields {
+		binary.Write(&data, binary.LittleEndian, f)
+	}
+	binary.Write(tr, binary.LittleEndian, typ)
+	binary.Write(tr, binary.LittleEndian, uint16(data.Len()))
+	tr.Write(data.Bytes())
+}
+
+// newTestWorkbook returns a workbook stream with a 'Plan PT 1' worksheet (and a chart sheet), padded with an ignored record of given size
+func newTestWorkbook(padding int) []byte {
+	tr := &testRecords{}
+	tr.add(recBOF, biff8Version, uint16(0x0005), make([]byte, 12))
+	if padding > 0 {
+		tr.add(0x00E1, make([]byte, padding))
+	}
+	tr.add(recXF, make([]byte, 20))
+	// left and bottom thin borders (color index 8), solid fill (color index 8)
+	tr.add(recXF, make([]byte, 10), uint32(0x1|0x1<<12|8<<16), uint32(1<<26), uint16(8|9<<7))
+	tr.add(recPalette, uint16(1), []byte{0x12, 0x34, 0x56, 0})
+	// 'Épissure' second string is split by a CONTINUE record, and goes on with 16 bits characters
+	tr.add(recSST, uint32(2), uint32(2), uint16(4), byte(0), []byte("PT 1"), uint16(8), byte(0), []byte{0xC9, 'p', 'i'})
+	tr.add(recContinue, byte(1), utf16.Encode([]rune("ssure")))
+	sheetPos := tr.Len()
+	tr.add(recBoundSheet, uint32(0), byte(0), byte(0), byte(9), byte(0), []byte("Plan PT 1"))
+	tr.add(recBoundSheet, uint32(0), byte(0), byte(2), byte(5), byte(0), []byte("Chart"))
+	tr.add(recEOF)
+
+	// patch sheet BOF position
+	content := tr.Bytes()
+	binary.LittleEndian.PutUint32(content[sheetPos+4:], uint32(tr.Len()))
+
+	tr.add(recBOF, biff8Version, uint16(0x0010), make([]byte, 12))
+	tr.add(recColInfo, uint16(0), uint16(255), uint16(10*256), uint16(0), uint16(0), uint16(0))
+	tr.add(recLabelSST, uint16(1), uint16(8), uint16(0), uint32(0))
+	tr.add(recLabelSST, uint16(9), uint16(13), uint16(1), uint32(1))
+	tr.add(recNumber, uint16(9), uint16(11), uint16(0), float64(12))
+	tr.add(recRK, uint16(9), uint16(19), uint16(0), uint32(5<<2|0x02))
+	tr.add(recMulRK, uint16(10), uint16(0), uint16(0), uint32(123<<2|0x03), uint16(0), uint32(math.Float64bits(2.5)>>32), uint16(1))
+	tr.add(recMulBlank, uint16(11), uint16(2), uint16(1), uint16(1), uint16(1), uint16(4))
+	tr.add(recFormula, uint16(12), uint16(0), uint16(0), []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF}, make([]byte, 6))
+	tr.add(recString, uint16(7), byte(0), []byte("CABLE 1"))
+	tr.add(recBoolErr, uint16(12), uint16(1), uint16(0), byte(1), byte(0))
+	tr.add(recBoolErr, uint16(12), uint16(2), uint16(0), byte(0x2A), byte(1))
+	tr.add(recBOF, biff8Version, uint16(0x0020), make([]byte, 12)) // embedded chart
+	tr.add(recLabelSST, uint16(20), uint16(20), uint16(0), uint32(0))
+	tr.add(recEOF)
+	tr.add(recMergeCells, uint16(1), uint16(13), uint16(14), uint16(0), uint16(2))
+	tr.add(recEOF)
+	return tr.Bytes()
+}
+
+// newTestCompoundFile returns a compound file holding given Workbook stream, in mini stream if mini is set
+func newTestCompoundFile(stream []byte, mini bool) []byte {
+	const sectorSize, miniSectorSize = 512, 64
+	sectors := func(size, unit int) int {
+		return (size + unit - 1) / unit
+	}
+	fat := []uint32{0xFFFFFFFD, cfbEndOfChain}
+	chain := func(table []uint32, first, nb int) []uint32 {
+		for i := first; i < first+nb-1; i++ {
+			table = append(table, uint32(i+1))
+		}
+		return append(table, cfbEndOfChain)
+	}
+	var data []byte
+	var miniFat []uint32
+	rootStart, rootSize, streamStart := cfbEndOfChain, 0, uint32(2)
+	if mini {
+		nbMini := sectors(len(stream), miniSectorSize)
+		miniFat = chain(miniFat, 0, nbMini)
+		data = make([]byte, sectors(nbMini*miniSectorSize, sectorSize)*sectorSize)
+		fat = append(fat, cfbEndOfChain) // mini FAT sector
+		fat = chain(fat, 3, len(data)/sectorSize)
+		rootStart, rootSize, streamStart = 3, nbMini*miniSectorSize, 0
+	} else {
+		data = make([]byte, sectors(len(stream), sectorSize)*sectorSize)
+		fat = chain(fat, 2, len(data)/sectorSize)
+	}
+	copy(data, stream)
+
+	sector := func(entries []uint32) []byte {
+		res := bytes.Repeat([]byte{0xFF}, sectorSize)
+		for i, e := range entries {
+			binary.LittleEndian.PutUint32(res[4*i:], e)
+		}
+		return res
+	}
+	entry := func(name string, typ byte, start uint32, size int) []byte {
+		res := make([]byte, 128)
+		u := utf16.Encode([]rune(name))
+		for i, c := range u {
+			binary.LittleEndian.PutUint16(res[2*i:], c)
+		}
+		binary.LittleEndian.PutUint16(res[64:], uint16(2*len(u)+2))
+		res[66] = typ
+		binary.LittleEndian.PutUint32(res[116:], start)
+		binary.LittleEndian.PutUint32(res[120:], uint32(size))
+		return res
+	}
+
+	header := bytes.Repeat([]byte{0xFF}, sectorSize)
+	copy(header, make([]byte, 0x4C))
+	binary.LittleEndian.PutUint64(header, cfbSignature)
+	binary.LittleEndian.PutUint16(header[0x18:], 0x3E)
+	binary.LittleEndian.PutUint16(header[0x1A:], 3)
+	binary.LittleEndian.PutUint16(header[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[0x1E:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2C:], 1)
+	binary.LittleEndian.PutUint32(header[0x30:], 1)
+	binary.LittleEndian.PutUint32(header[0x38:], 4096)
+	binary.LittleEndian.PutUint32(header[0x3C:], cfbEndOfChain)
+	binary.LittleEndian.PutUint32(header[0x44:], cfbEndOfChain)
+	binary.LittleEndian.PutUint32(header[0x4C:], 0)
+	if mini {
+		binary.LittleEndian.PutUint32(header[0x3C:], 2)
+		binary.LittleEndian.PutUint32(header[0x40:], 1)
+	}
+
+	res := bytes.Buffer{}
+	res.Write(header)
+	res.Write(sector(fat))
+	dir := bytes.Buffer{}
+	dir.Write(entry("Root Entry", cfbTypeRoot, rootStart, rootSize))
+	dir.Write(entry("Workbook", cfbTypeStream, streamStart, len(stream)))
+	dir.Write(make([]byte, 2*128))
+	res.Write(dir.Bytes())
+	if mini {
+		res.Write(sector(miniFat))
+	}
+	res.Write(data)
+	return res.Bytes()
+}
+
+func TestReadXls(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		padding int
+		mini    bool
+	}{
+		{"regular stream", 5000, false},
+		{"mini stream", 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			xf, err := ReadXls(newTestCompoundFile(newTestWorkbook(tc.padding), tc.mini))
+			if err != nil {
+				t.Fatalf("ReadXls returned unexpected: %s", err.Error())
+			}
+			if len(xf.Sheets) != 1 || xf.Sheets[0].Name != "Plan PT 1" {
+				t.Fatalf("unexpected sheets %v", xf.Sheet)
+			}
+			sheet := xf.Sheets[0]
+			for _, c := range []struct {
+				row, col int
+				value    string
+			}{
+				{1, 8, "PT 1"},
+				{9, 13, "Épissure"},
+				{9, 11, "12"},
+				{9, 19, "5"},
+				{10, 0, "1.23"},
+				{10, 1, "2.5"},
+				{12, 0, "CABLE 1"},
+				{12, 1, "1"},
+				{12, 2, "#N/A"},
+			} {
+				if value := sheet.Cell(c.row, c.col).Value; value != c.value {
+					t.Errorf("cell (%d, %d) has value '%s' instead of '%s'", c.row, c.col, value, c.value)
+				}
+			}
+			if sheet.MaxRow != 14 || sheet.MaxCol != 20 || len(sheet.Cols) != 20 || sheet.Cols[0].Width != 10 {
+				t.Errorf("unexpected sheet size %d x %d", sheet.MaxRow, sheet.MaxCol)
+			}
+			for _, col := range []int{2, 3, 4} {
+				st := sheet.Cell(11, col).GetStyle()
+				if st.Border.Left != "thin" || st.Border.Bottom != "thin" || st.Border.Top != "none" || st.Border.LeftColor != "FF123456" {
+					t.Errorf("cell (11, %d) has unexpected border %v", col, st.Border)
+				}
+				if st.Fill.PatternType != "solid" || st.Fill.FgColor != "FF123456" || st.Fill.BgColor != "FFFFFFFF" {
+					t.Errorf("cell (11, %d) has unexpected fill %v", col, st.Fill)
+				}
+			}
+			if st := sheet.Cell(1, 8).GetStyle(); st.Border.Left != "none" || st.Fill.FgColor != "" {
+				t.Errorf("cell (1, 8) has unexpected style")
+			}
+			if c := sheet.Cell(13, 0); c.HMerge != 2 || c.VMerge != 1 {
+				t.Errorf("unexpected merged cells %d x %d", c.HMerge, c.VMerge)
+			}
+		})
+	}
+}
+
+func TestReadXls_InvalidShifts(t *testing.T) {
+	for _, tc := range []struct {
+		offset int
+		shift  uint16
+	}{
+		{0x1E, 0},
+		{0x1E, 64},
+		{0x20, 0},
+		{0x20, 9},
+	} {
+		content := newTestCompoundFile(newTestWorkbook(0), true)
+		binary.LittleEndian.PutUint16(content[tc.offset:], tc.shift)
+		if _, err := ReadXls(content); err == nil {
+			t.Errorf("ReadXls should fail on shift %d at offset 0x%X", tc.shift, tc.offset)
+		}
+	}
+}
+
+func TestConvertToXlsx(t *testing.T) {
+	xlsFile := filepath.Join(t.TempDir(), "PT 1.xls")
+	err := ioutil.WriteFile(xlsFile, newTestCompoundFile(newTestWorkbook(0), true), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outFile, err := ConvertToXlsx(xlsFile)
+	if err != nil {
+		t.Fatalf("ConvertToXlsx returned unexpected: %s", err.Error())
+	}
+	xf, err := OpenFile(outFile)
+	if err != nil {
+		t.Fatalf("could not open converted file: %s", err.Error())
+	}
+	sheet := xf.Sheet["Plan PT 1"]
+	if sheet == nil || sheet.Cell(9, 13).Value != "Épissure" || sheet.Cell(11, 2).GetStyle().Border.Left != "thin" {
+		t.Errorf("unexpected converted file content")
+	}
+}